@@ -0,0 +1,103 @@
+package gosocketio_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+type ackResult struct {
+	err      error
+	response []interface{}
+}
+
+// emitWithTimeout connects a client and emits "question" to it with a 5s
+// acknowledgment timeout. The results of the ack callback are sent to the
+// returned channel.
+func emitWithTimeout(t *testing.T) (*siotest.Server, *siotest.Client, *gosocketio.Socket, chan ackResult) {
+	t.Helper()
+
+	server := siotest.NewServer(t, nil)
+	sockets := make(chan *gosocketio.Socket, 1)
+	server.OnConnect(func(socket *gosocketio.Socket) { sockets <- socket })
+	alice := server.Connect(t, "/")
+	socket := <-sockets
+
+	results := make(chan ackResult, 2)
+	err := socket.Timeout(5*time.Second).EmitWithAck("question", func(err error, response ...interface{}) {
+		results <- ackResult{err, response}
+	}, "name?")
+	if err != nil {
+		t.Fatalf("EmitWithAck: %v", err)
+	}
+	return server, alice, socket, results
+}
+
+func expectAckResult(t *testing.T, results chan ackResult) ackResult {
+	t.Helper()
+
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(time.Second):
+		t.Fatal("acknowledgment callback not called")
+		return ackResult{}
+	}
+}
+
+func expectNoAckResult(t *testing.T, results chan ackResult) {
+	t.Helper()
+
+	select {
+	case result := <-results:
+		t.Fatalf("acknowledgment callback called again with %+v", result)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTimeoutEmitterExpires(t *testing.T) {
+	server, alice, _, results := emitWithTimeout(t)
+	event := alice.ExpectEvent(t, "question", time.Second)
+
+	// The timeout follows the server clock
+	server.Clock.Advance(4 * time.Second)
+	expectNoAckResult(t, results)
+	server.Clock.Advance(time.Second)
+	if result := expectAckResult(t, results); !errors.Is(result.err, context.DeadlineExceeded) {
+		t.Fatalf("got %+v, want context.DeadlineExceeded", result)
+	}
+
+	// A late answer is ignored
+	event.Ack("alice")
+	expectNoAckResult(t, results)
+}
+
+func TestTimeoutEmitterAnswered(t *testing.T) {
+	server, alice, _, results := emitWithTimeout(t)
+
+	alice.ExpectEvent(t, "question", time.Second).Ack("alice")
+	result := expectAckResult(t, results)
+	if result.err != nil || len(result.response) != 1 || result.response[0] != "alice" {
+		t.Fatalf("got %+v, want the answer", result)
+	}
+
+	server.Clock.Advance(5 * time.Second)
+	expectNoAckResult(t, results)
+}
+
+func TestTimeoutEmitterDisconnected(t *testing.T) {
+	server, alice, socket, results := emitWithTimeout(t)
+	alice.ExpectEvent(t, "question", time.Second)
+
+	socket.Disconnect()
+	if result := expectAckResult(t, results); !errors.Is(result.err, gosocketio.ErrSocketDisconnected) {
+		t.Fatalf("got %+v, want ErrSocketDisconnected", result)
+	}
+
+	server.Clock.Advance(5 * time.Second)
+	expectNoAckResult(t, results)
+}
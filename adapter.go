@@ -1,5 +1,10 @@
 package gosocketio
 
+import (
+	"context"
+	"fmt"
+)
+
 // Adapter is the interface for managing rooms and broadcasting in Socket.IO.
 //
 // Adapters are responsible for:
@@ -8,8 +13,9 @@ package gosocketio
 //   - Cleaning up resources on shutdown
 //
 // The default implementation is MemoryAdapter, which stores everything in memory.
-// For multi-server deployments, custom adapters can be implemented (e.g., RedisAdapter)
-// to synchronize rooms and broadcasts across multiple server instances.
+// For multi-server deployments, the redisadapter package synchronizes broadcasts
+// and room queries across multiple server instances through Redis, and custom
+// adapters can be implemented for other backends.
 //
 // Example custom adapter:
 //
@@ -55,10 +61,77 @@ type Adapter interface {
 	//
 	// If rooms is empty or nil, broadcasts to all sockets in the namespace.
 	// The except parameter allows excluding specific sockets from the broadcast.
-	Broadcast(packet *Packet, rooms []string, except []string) error
+	//
+	// ctx bounds the wait for sockets whose outgoing queue is full when
+	// Config.OverflowPolicy is engineio.OverflowBlock. Sockets that could
+	// not be sent the packet are reported with a *BroadcastError.
+	Broadcast(ctx context.Context, packet *Packet, rooms []string, except []string) error
 
 	// Close cleans up any resources used by the adapter.
 	//
 	// This is called when the namespace is being shut down.
 	Close() error
 }
+
+// AckAdapter is an optional interface for adapters that can broadcast packets
+// expecting an acknowledgment from every recipient.
+//
+// It is required by BroadcastOperator.EmitWithAck. MemoryAdapter implements it.
+type AckAdapter interface {
+	Adapter
+
+	// BroadcastWithAck sends a packet to the targeted sockets like Broadcast,
+	// giving each recipient its own ack ID.
+	//
+	// onTargets must be called once per server with the IDs of the sockets
+	// the packet is sent to, before any of their acknowledgments are
+	// reported. onAck is then called once for each of them, with either its
+	// response or a non-nil err if the packet could not be sent to it or it
+	// disconnected before answering.
+	//
+	// Once ctx is done no more acknowledgments are expected and any state kept
+	// for them should be released.
+	BroadcastWithAck(ctx context.Context, packet *Packet, rooms []string, except []string,
+		onTargets func(socketIDs []string), onAck func(socketID string, args []interface{}, err error)) error
+
+	// ServerCount returns the number of servers in the cluster, which is the
+	// number of times onTargets is called for each BroadcastWithAck.
+	ServerCount() int
+}
+
+// LocalBroadcaster is an optional interface for adapters spanning several
+// servers that can restrict a broadcast to the sockets connected to the
+// local server. It is used by BroadcastOperator.Local.
+type LocalBroadcaster interface {
+	// BroadcastLocal behaves like Broadcast but only reaches local sockets.
+	BroadcastLocal(ctx context.Context, packet *Packet, rooms []string, except []string) error
+}
+
+// BroadcastError is returned by broadcasts that could not send the packet to
+// some of the targeted sockets, for instance because their outgoing queue was
+// full or they disconnected meanwhile. The other sockets were sent the packet.
+type BroadcastError struct {
+	// Errors maps the ID of each socket that was not sent the packet to the
+	// reason, such as engineio.ErrSlowClient.
+	Errors map[string]error
+}
+
+func (e *BroadcastError) Error() string {
+	for id, err := range e.Errors {
+		if len(e.Errors) == 1 {
+			return fmt.Sprintf("broadcast to socket %s failed: %v", id, err)
+		}
+		return fmt.Sprintf("broadcast to %d sockets failed, including %s: %v", len(e.Errors), id, err)
+	}
+	return "broadcast failed"
+}
+
+// Unwrap returns the errors of the sockets, so that errors.Is reports whether
+// any socket failed with a given error.
+func (e *BroadcastError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
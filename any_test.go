@@ -0,0 +1,151 @@
+package gosocketio_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+type call struct {
+	handler string
+	event   string
+	args    []interface{}
+}
+
+// recordCall returns an AnyHandler sending its calls to calls
+func recordCall(calls chan call, handler string) gosocketio.AnyHandler {
+	return func(event string, args ...interface{}) {
+		calls <- call{handler, event, args}
+	}
+}
+
+// expectCalls checks that the handlers were called in order, and no others
+func expectCalls(t *testing.T, calls chan call, handlers ...string) []call {
+	t.Helper()
+
+	var got []call
+	for _, handler := range handlers {
+		select {
+		case c := <-calls:
+			if c.handler != handler {
+				t.Fatalf("%s called for %q, want %s", c.handler, c.event, handler)
+			}
+			got = append(got, c)
+		case <-time.After(time.Second):
+			t.Fatalf("%s not called", handler)
+		}
+	}
+	select {
+	case c := <-calls:
+		t.Fatalf("%s called for %q, want no more calls", c.handler, c.event)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return got
+}
+
+func TestOnAny(t *testing.T) {
+	server := siotest.NewServer(t, &gosocketio.Config{DispatchMode: gosocketio.DispatchOrdered})
+	calls := make(chan call, 16)
+	sockets := make(chan *gosocketio.Socket, 1)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.Use(func(event string, args []interface{}, next func(error)) {
+			if event == "forbidden" {
+				next(errors.New("not allowed"))
+				return
+			}
+			next(nil)
+		})
+		socket.OnAny(recordCall(calls, "any"))
+		socket.PrependAny(recordCall(calls, "prepended"))
+		socket.On("chat", func(args ...interface{}) {
+			calls <- call{"chat", "chat", args}
+			args[len(args)-1].(func(...interface{}))("ok")
+		})
+		sockets <- socket
+	})
+	alice := server.Connect(t, "/")
+	socket := <-sockets
+
+	// Catch-all handlers run ahead of the event handlers, with the ack
+	alice.ExpectAck(t, time.Second, "chat", "hi")
+	got := expectCalls(t, calls, "prepended", "any", "chat")
+	for _, c := range got[:2] {
+		if c.event != "chat" || len(c.args) != 2 || c.args[0] != "hi" {
+			t.Fatalf("%s got %q %v, want the event and its ack", c.handler, c.event, c.args)
+		}
+		if _, ok := c.args[1].(func(...interface{})); !ok {
+			t.Fatalf("%s got %#v, want the ack function", c.handler, c.args[1])
+		}
+	}
+
+	// Events without handlers are seen too, but not the rejected ones
+	if err := alice.Emit("chta", "typo"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	if got := expectCalls(t, calls, "prepended", "any"); got[1].event != "chta" || len(got[1].args) != 1 {
+		t.Fatalf("got %q %v, want the unhandled event", got[1].event, got[1].args)
+	}
+	if err := alice.Emit("forbidden"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	alice.ExpectEvent(t, "error", time.Second)
+	expectCalls(t, calls)
+
+	socket.OffAny()
+	alice.ExpectAck(t, time.Second, "chat", "again")
+	expectCalls(t, calls, "chat")
+}
+
+func TestOnAnyOutgoing(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	calls := make(chan call, 16)
+	sockets := make(chan *gosocketio.Socket, 1)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.On("echo", echo)
+		socket.OnAnyOutgoing(recordCall(calls, "first"))
+		socket.OnAnyOutgoing(recordCall(calls, "second"))
+		sockets <- socket
+	})
+	alice := server.Connect(t, "/")
+	socket := <-sockets
+
+	// The handlers are called in order before Emit returns
+	if err := socket.Emit("news", "a", 1); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	for _, handler := range []string{"first", "second"} {
+		select {
+		case c := <-calls:
+			if c.handler != handler || c.event != "news" || len(c.args) != 2 || c.args[0] != "a" || c.args[1] != 1 {
+				t.Fatalf("got %+v, want %s called with the arguments", c, handler)
+			}
+		default:
+			t.Fatalf("%s not called by Emit", handler)
+		}
+	}
+	alice.ExpectEvent(t, "news", time.Second)
+
+	// Ack callbacks are not passed to the handlers
+	socket.EmitWithAck("question", func(response ...interface{}) {}, "life")
+	got := expectCalls(t, calls, "first", "second")
+	if got[0].event != "question" || len(got[0].args) != 1 || got[0].args[0] != "life" {
+		t.Fatalf("got %q %v, want the arguments without the callback", got[0].event, got[0].args)
+	}
+	alice.ExpectEvent(t, "question", time.Second).Ack()
+
+	// Neither broadcasts nor acknowledgments are reported
+	if err := server.Emit("broadcast"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	alice.ExpectEvent(t, "broadcast", time.Second)
+	expectEcho(t, alice)
+	expectCalls(t, calls)
+
+	socket.OffAnyOutgoing()
+	socket.Emit("news")
+	alice.ExpectEvent(t, "news", time.Second)
+	expectCalls(t, calls)
+}
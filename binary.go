@@ -0,0 +1,231 @@
+package gosocketio
+
+import "fmt"
+
+// Binary data is sent by replacing every []byte value in the packet data with
+// a placeholder object {"_placeholder":true,"num":N} and sending the N-th
+// buffer as a separate binary Engine.IO message right after the packet.
+//
+// Only []byte values nested in []interface{} and map[string]interface{} are
+// detected. Other values are marshaled with encoding/json as usual, which
+// base64-encodes []byte struct fields.
+
+// EncodeWithAttachments encodes the packet like Encode, but first extracts
+// []byte values from its data as binary attachments.
+//
+// It is meant for adapters that forward packets between servers.
+func (p *Packet) EncodeWithAttachments() (string, [][]byte, error) {
+	packet := p
+	var buffers [][]byte
+	if hasBinary(p.Data) {
+		packet, buffers = deconstructPacket(p)
+	}
+
+	encoded, err := packet.Encode()
+	if err != nil {
+		return "", nil, err
+	}
+	return encoded, buffers, nil
+}
+
+// DecodePacketWithAttachments decodes a packet encoded by EncodeWithAttachments,
+// putting the binary attachments back in place of their placeholders.
+//
+// Unlike DecodePacket, it decodes the data into interface{} values, so that
+// the packet can be encoded again by any parser.
+func DecodePacketWithAttachments(data string, attachments [][]byte) (*Packet, error) {
+	packet, err := DecodePacket(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if packet.Attachments != len(attachments) {
+		return nil, fmt.Errorf("expected %d attachments, got %d", packet.Attachments, len(attachments))
+	}
+
+	if len(attachments) > 0 {
+		err = reconstructPacket(packet, attachments)
+	} else {
+		packet.Data, err = packet.DecodedData()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+func hasBinary(data interface{}) bool {
+	switch v := data.(type) {
+	case []byte:
+		return true
+	case []interface{}:
+		for _, item := range v {
+			if hasBinary(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if hasBinary(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deconstructPacket returns a copy of the packet with binary values replaced by
+// placeholders, along with the extracted buffers. The original is not modified
+// so it can be shared between broadcast recipients.
+func deconstructPacket(packet *Packet) (*Packet, [][]byte) {
+	var buffers [][]byte
+
+	result := *packet
+	result.Data = deconstructData(packet.Data, &buffers)
+	result.Attachments = len(buffers)
+
+	switch packet.Type {
+	case PacketTypeEvent:
+		result.Type = PacketTypeBinaryEvent
+	case PacketTypeAck:
+		result.Type = PacketTypeBinaryAck
+	}
+
+	return &result, buffers
+}
+
+func deconstructData(data interface{}, buffers *[][]byte) interface{} {
+	switch v := data.(type) {
+	case []byte:
+		placeholder := map[string]interface{}{"_placeholder": true, "num": len(*buffers)}
+		*buffers = append(*buffers, v)
+		return placeholder
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = deconstructData(item, buffers)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = deconstructData(item, buffers)
+		}
+		return result
+	default:
+		return data
+	}
+}
+
+// reconstructPacket replaces placeholders in the packet data with the
+// received buffers.
+func reconstructPacket(packet *Packet, buffers [][]byte) error {
+	data, err := packet.DecodedData()
+	if err != nil {
+		return err
+	}
+	if data, err = reconstructData(data, buffers); err != nil {
+		return err
+	}
+	packet.Data = data
+	packet.Attachments = 0
+	return nil
+}
+
+func reconstructData(data interface{}, buffers [][]byte) (interface{}, error) {
+	switch v := data.(type) {
+	case []interface{}:
+		for i, item := range v {
+			item, err := reconstructData(item, buffers)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = item
+		}
+	case map[string]interface{}:
+		if placeholder, _ := v["_placeholder"].(bool); placeholder {
+			num, ok := v["num"].(float64)
+			if !ok || num < 0 || int(num) >= len(buffers) {
+				return nil, fmt.Errorf("invalid attachment placeholder: %v", v["num"])
+			}
+			return buffers[int(num)], nil
+		}
+		for key, item := range v {
+			item, err := reconstructData(item, buffers)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = item
+		}
+	}
+	return data, nil
+}
+
+// binaryDecoder reassembles binary packets from a text packet followed by
+// its binary attachments.
+type binaryDecoder struct {
+	packet  *Packet
+	buffers [][]byte
+
+	// maxAttachments is the maximum number of attachments of a packet
+	maxAttachments int
+}
+
+// decodeText decodes a text message. It returns nil if the packet is waiting
+// for binary attachments.
+func (d *binaryDecoder) decodeText(data []byte) (*Packet, error) {
+	if d.packet != nil {
+		d.reset()
+		return nil, fmt.Errorf("got text packet while reconstructing binary packet")
+	}
+
+	packet, err := DecodePacket(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if packet.Attachments > d.maxAttachments {
+		d.reset()
+		return nil, fmt.Errorf("too many attachments: %d", packet.Attachments)
+	}
+	if packet.Attachments > 0 {
+		d.packet = packet
+		return nil, nil
+	}
+
+	return packet, nil
+}
+
+// decodeBinary adds an attachment to the pending packet. It returns the
+// packet once all of its attachments have been received.
+func (d *binaryDecoder) decodeBinary(data []byte) (*Packet, error) {
+	if d.packet == nil {
+		return nil, fmt.Errorf("got binary data when not reconstructing a packet")
+	}
+
+	d.buffers = append(d.buffers, data)
+	if len(d.buffers) < d.packet.Attachments {
+		return nil, nil
+	}
+
+	packet, buffers := d.packet, d.buffers
+	d.reset()
+
+	if err := reconstructPacket(packet, buffers); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// Add decodes a frame, implementing Decoder
+func (d *binaryDecoder) Add(frame Frame) (*Packet, error) {
+	if frame.Binary {
+		return d.decodeBinary(frame.Data)
+	}
+	return d.decodeText(frame.Data)
+}
+
+func (d *binaryDecoder) reset() {
+	d.packet = nil
+	d.buffers = nil
+}
@@ -0,0 +1,49 @@
+package gosocketio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBinaryDecoderReconstructsAttachments(t *testing.T) {
+	d := JSONParser{}.NewDecoder()
+
+	packet, err := d.Add(Frame{Data: []byte(`51-["upload",{"_placeholder":true,"num":0}]`)})
+	if err != nil || packet != nil {
+		t.Fatalf("got packet %v, error %v, want to wait for the attachment", packet, err)
+	}
+
+	packet, err = d.Add(Frame{Data: []byte{1, 2, 3}, Binary: true})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	args, _ := packet.Data.([]interface{})
+	if len(args) != 2 || args[0] != "upload" || !bytes.Equal(args[1].([]byte), []byte{1, 2, 3}) {
+		t.Fatalf("got data %v", packet.Data)
+	}
+}
+
+func TestBinaryDecoderMaxAttachments(t *testing.T) {
+	d := JSONParser{MaxAttachments: 2}.NewDecoder()
+
+	if _, err := d.Add(Frame{Data: []byte(`53-["upload"]`)}); err == nil {
+		t.Fatal("accepted a packet with more attachments than the limit")
+	}
+
+	// The decoder is not left waiting for the attachments
+	if _, err := d.Add(Frame{Data: []byte{1}, Binary: true}); err == nil {
+		t.Fatal("accepted an attachment after rejecting its packet")
+	}
+	packet, err := d.Add(Frame{Data: []byte(`2["message"]`)})
+	if err != nil || packet == nil {
+		t.Fatalf("got packet %v, error %v after the rejected packet", packet, err)
+	}
+}
+
+func TestBinaryDecoderDefaultMaxAttachments(t *testing.T) {
+	d := JSONParser{}.NewDecoder()
+
+	if _, err := d.Add(Frame{Data: []byte(`51000000-["upload"]`)}); err == nil {
+		t.Fatal("accepted a packet declaring a million attachments")
+	}
+}
@@ -0,0 +1,74 @@
+package gosocketio_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+func TestBroadcastEmitWithAck(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	alice := server.Connect(t, "/")
+	bob := server.Connect(t, "/")
+
+	done := make(chan struct{})
+	var (
+		result *gosocketio.BroadcastAckResult
+		err    error
+	)
+	go func() {
+		defer close(done)
+		result, err = server.Timeout(5*time.Second).EmitWithAckContext(context.Background(), "ping")
+	}()
+
+	alice.ExpectEvent(t, "ping", time.Second).Ack("pong")
+	bob.ExpectEvent(t, "ping", time.Second).Ack("pong")
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast acknowledgments not collected")
+	}
+	if err != nil {
+		t.Fatalf("EmitWithAckContext: %v", err)
+	}
+	if len(result.Responses) != 2 || len(result.Missing) != 0 {
+		t.Fatalf("got %d responses, missing %v", len(result.Responses), result.Missing)
+	}
+}
+
+func TestBroadcastEmitWithAckDisconnectedTarget(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	alice := server.Connect(t, "/")
+	bob := server.Connect(t, "/")
+	bobID := bob.ID()
+
+	acks := make(chan error, 1)
+	err := server.Of("/").To().EmitWithAck("ping", func(err error, result *gosocketio.BroadcastAckResult) {
+		if len(result.Missing) != 1 || result.Missing[0] != bobID {
+			t.Errorf("missing %v, want [%s]", result.Missing, bobID)
+		}
+		acks <- err
+	})
+	if err != nil {
+		t.Fatalf("EmitWithAck: %v", err)
+	}
+
+	alice.ExpectEvent(t, "ping", time.Second).Ack("pong")
+	bob.ExpectEvent(t, "ping", time.Second)
+	bob.Disconnect()
+
+	// Without a timeout, the ack is only called because bob is reported
+	select {
+	case err := <-acks:
+		if !errors.Is(err, gosocketio.ErrSocketDisconnected) {
+			t.Fatalf("got error %v, want ErrSocketDisconnected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ack not called after a target disconnected")
+	}
+}
@@ -0,0 +1,240 @@
+package gosocketio
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramory-l/gosocketio/engineio"
+)
+
+// errInvalidNamespace is sent to clients connecting to a namespace that does
+// not exist
+var errInvalidNamespace = errors.New("Invalid namespace")
+
+// client represents a single Engine.IO session and the sockets it holds
+// in each namespace it has connected to.
+//
+// A client does not belong to any namespace until it sends a CONNECT
+// packet. If no namespace is joined within the connect timeout, the
+// underlying session is closed.
+type client struct {
+	server       *Server
+	session      *engineio.Session
+	sockets      map[string]*Socket // namespace name -> socket
+	connecting   map[string]bool    // namespaces with middlewares running
+	closed       bool
+	mu           sync.RWMutex
+	decoder      Decoder
+	decoderMu    sync.Mutex
+	connectTimer engineio.Timer
+}
+
+func newClient(server *Server, session *engineio.Session) *client {
+	c := &client{
+		server:     server,
+		session:    session,
+		sockets:    make(map[string]*Socket),
+		connecting: make(map[string]bool),
+		decoder:    server.parser().NewDecoder(),
+	}
+
+	c.connectTimer = server.clock().AfterFunc(time.Duration(server.config.ConnectTimeout)*time.Millisecond, func() {
+		c.mu.RLock()
+		connected := len(c.sockets) > 0
+		c.mu.RUnlock()
+
+		if !connected {
+			session.Close("connect timeout")
+		}
+	})
+
+	session.OnMessage(c.handleMessage)
+	session.OnBinaryMessage(c.handleBinary)
+	session.OnClose(c.handleClose)
+
+	// Socket.IO v2 clients are connected to the main namespace without
+	// sending a CONNECT packet
+	if c.legacy() {
+		c.connect(&Packet{Type: PacketTypeConnect, Namespace: "/"})
+	}
+
+	return c
+}
+
+// legacy reports whether the client speaks Socket.IO v2, over Engine.IO v3
+func (c *client) legacy() bool {
+	return c.session.Protocol() == engineio.ProtocolV3
+}
+
+func (c *client) handleMessage(data []byte) {
+	c.decoderMu.Lock()
+	packet, err := c.decoder.Add(Frame{Data: data})
+	c.decoderMu.Unlock()
+
+	if err != nil || packet == nil {
+		return
+	}
+
+	c.dispatch(packet)
+}
+
+func (c *client) handleBinary(data []byte) {
+	c.decoderMu.Lock()
+	packet, err := c.decoder.Add(Frame{Data: data, Binary: true})
+	c.decoderMu.Unlock()
+
+	if err != nil || packet == nil {
+		return
+	}
+
+	c.dispatch(packet)
+}
+
+func (c *client) dispatch(packet *Packet) {
+	if packet.Type == PacketTypeConnect {
+		c.connect(packet)
+		return
+	}
+
+	c.mu.RLock()
+	socket, ok := c.sockets[packet.Namespace]
+	c.mu.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	socket.handlePacket(packet)
+}
+
+func (c *client) connect(packet *Packet) {
+	data, _ := packet.DecodedData()
+	auth, _ := data.(map[string]interface{})
+
+	// Socket.IO v2 clients have no auth payload but may append a query to
+	// the namespace, which is used as the auth instead
+	if c.legacy() {
+		var query string
+		packet.Namespace, query, _ = strings.Cut(packet.Namespace, "?")
+		if values, err := url.ParseQuery(query); err == nil && len(values) > 0 {
+			auth = make(map[string]interface{}, len(values))
+			for key := range values {
+				auth[key] = values.Get(key)
+			}
+		}
+	}
+
+	ns, ok := c.server.reserveNamespace(packet.Namespace)
+	if !ok {
+		ns, ok = c.server.parentNamespace(packet.Namespace, auth)
+	}
+	if !ok {
+		c.sendPacket(c.connectErrorPacket(packet.Namespace, errInvalidNamespace))
+		return
+	}
+
+	c.mu.Lock()
+	_, connected := c.sockets[ns.name]
+	if connected || c.connecting[ns.name] || c.closed {
+		c.mu.Unlock()
+		ns.admit(nil)
+		ns.cleanup()
+		return
+	}
+	c.connecting[ns.name] = true
+	c.mu.Unlock()
+
+	ns.addSocket(c, auth)
+}
+
+// rejectSocket reports a connection refused by the namespace middlewares
+func (c *client) rejectSocket(namespace string, err error) {
+	c.mu.Lock()
+	delete(c.connecting, namespace)
+	c.mu.Unlock()
+
+	c.sendPacket(c.connectErrorPacket(namespace, err))
+}
+
+// connectErrorPacket returns the packet refusing a connection to the
+// namespace. Socket.IO v2 clients expect an ERROR packet whose data is the
+// error message, or the ConnectError data if set.
+func (c *client) connectErrorPacket(namespace string, err error) *Packet {
+	var connectErr *ConnectError
+	errors.As(err, &connectErr)
+
+	if !c.legacy() {
+		data := map[string]interface{}{"message": err.Error()}
+		if connectErr != nil && connectErr.Data != nil {
+			data["data"] = connectErr.Data
+		}
+		return &Packet{Type: PacketTypeConnectError, Namespace: namespace, Data: data}
+	}
+
+	var data interface{} = err.Error()
+	if connectErr != nil && connectErr.Data != nil {
+		data = connectErr.Data
+	}
+	return &Packet{Type: PacketTypeConnectError, Namespace: namespace, Data: data}
+}
+
+// addSocket registers a socket that passed the namespace middlewares.
+// It returns false if the client has already been closed.
+func (c *client) addSocket(socket *Socket) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.connecting, socket.namespace.name)
+	if c.closed {
+		return false
+	}
+
+	c.sockets[socket.namespace.name] = socket
+	c.connectTimer.Stop()
+	return true
+}
+
+func (c *client) removeSocket(socket *Socket) {
+	c.mu.Lock()
+	if c.sockets[socket.namespace.name] == socket {
+		delete(c.sockets, socket.namespace.name)
+	}
+	c.mu.Unlock()
+}
+
+func (c *client) sendPacket(packet *Packet) error {
+	packets, err := encodePacket(c.server.parser(), packet)
+	if err != nil {
+		return err
+	}
+
+	return c.session.SendContext(context.Background(), packets...)
+}
+
+func (c *client) handleClose(reason string) {
+	c.connectTimer.Stop()
+
+	c.mu.Lock()
+	c.closed = true
+	sockets := make([]*Socket, 0, len(c.sockets))
+	for _, socket := range c.sockets {
+		sockets = append(sockets, socket)
+	}
+	c.mu.Unlock()
+
+	for _, socket := range sockets {
+		socket.handleClose(reason)
+	}
+}
+
+func generateID() string {
+	b := make([]byte, 15)
+	rand.Read(b)
+	return base64.URLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,315 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/client"
+	"github.com/ramory-l/gosocketio/engineio"
+)
+
+// testServer is a gosocketio.Server listening on a local port, that can be
+// taken down to refuse new requests
+type testServer struct {
+	*gosocketio.Server
+	http *httptest.Server
+	URL  string
+
+	down       atomic.Bool
+	handshakes atomic.Int32
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{Server: gosocketio.NewServer(nil)}
+	s.http = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Query().Get("sid") == "" {
+			s.handshakes.Add(1)
+		}
+		s.Server.ServeHTTP(w, r)
+	}))
+	s.URL = s.http.URL
+
+	t.Cleanup(func() {
+		s.http.Close()
+		s.Server.Close()
+	})
+	return s
+}
+
+func connect(t *testing.T, rawURL string, opts *client.Options) *client.Socket {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	socket, err := client.Connect(ctx, rawURL, opts)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { socket.Manager().Close() })
+	return socket
+}
+
+func emitWithAck(t *testing.T, socket *client.Socket, event string, data ...interface{}) []interface{} {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response, err := socket.EmitWithAckContext(ctx, event, data...)
+	if err != nil {
+		t.Fatalf("EmitWithAckContext(%s): %v", event, err)
+	}
+	return response
+}
+
+// echo acknowledges an event with its arguments
+func echo(args ...interface{}) {
+	ack := args[len(args)-1].(func(...interface{}))
+	ack(args[:len(args)-1]...)
+}
+
+func TestConnectWithAuth(t *testing.T) {
+	server := newTestServer(t)
+	sockets := make(chan *gosocketio.Socket, 1)
+	chat := server.Of("/chat")
+	chat.Use(func(socket *gosocketio.Socket, next func(error)) {
+		if socket.Handshake().Auth["token"] != "abc" {
+			next(errors.New("unauthorized"))
+			return
+		}
+		next(nil)
+	})
+	chat.OnConnect(func(socket *gosocketio.Socket) { sockets <- socket })
+
+	socket := connect(t, server.URL+"/chat", &client.Options{
+		Auth: map[string]interface{}{"token": "abc"},
+	})
+	if !socket.Connected() || socket.Namespace() != "/chat" {
+		t.Fatalf("socket connected %v to %q", socket.Connected(), socket.Namespace())
+	}
+	if remote := <-sockets; remote.ID() != socket.ID() {
+		t.Fatalf("socket ID = %q, want %q", socket.ID(), remote.ID())
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	server := newTestServer(t)
+	server.Of("/chat").Use(func(socket *gosocketio.Socket, next func(error)) {
+		next(&gosocketio.ConnectError{Message: "unauthorized", Data: map[string]interface{}{"retry": false}})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.Connect(ctx, server.URL+"/chat", nil)
+	var connectErr *gosocketio.ConnectError
+	if !errors.As(err, &connectErr) || connectErr.Message != "unauthorized" {
+		t.Fatalf("Connect = %v, want the ConnectError", err)
+	}
+	if data, _ := connectErr.Data.(map[string]interface{}); data["retry"] != false {
+		t.Fatalf("ConnectError data = %v, want the middleware data", connectErr.Data)
+	}
+
+	_, err = client.Connect(ctx, server.URL+"/unknown", nil)
+	if !errors.As(err, &connectErr) || connectErr.Message != "Invalid namespace" {
+		t.Fatalf("Connect = %v, want Invalid namespace", err)
+	}
+}
+
+func TestEmitAndAck(t *testing.T) {
+	server := newTestServer(t)
+	sockets := make(chan *gosocketio.Socket, 1)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.On("echo", echo)
+		sockets <- socket
+	})
+
+	socket := connect(t, server.URL, nil)
+	remote := <-sockets
+
+	if response := emitWithAck(t, socket, "echo", "hello", 42); len(response) != 2 || response[0] != "hello" || response[1] != float64(42) {
+		t.Fatalf("got %v, want [hello 42]", response)
+	}
+
+	// The server asks the client for acknowledgments too
+	socket.On("question", func(args ...interface{}) {
+		args[len(args)-1].(func(...interface{}))("answer to " + args[0].(string))
+	})
+	answers := make(chan interface{}, 1)
+	remote.EmitWithAck("question", func(response ...interface{}) {
+		answers <- response[0]
+	}, "life")
+	select {
+	case answer := <-answers:
+		if answer != "answer to life" {
+			t.Fatalf("got %v, want the client answer", answer)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no acknowledgment from the client")
+	}
+}
+
+func TestBinary(t *testing.T) {
+	server := newTestServer(t)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.On("upload", func(args ...interface{}) {
+			data, _ := args[0].([]byte)
+			ack := args[len(args)-1].(func(...interface{}))
+			ack(len(data), append([]byte{0xff}, data...))
+		})
+	})
+
+	socket := connect(t, server.URL, nil)
+
+	response := emitWithAck(t, socket, "upload", []byte{1, 2, 3})
+	if len(response) != 2 || response[0] != float64(3) {
+		t.Fatalf("got %v, want the received length and data", response)
+	}
+	if data, ok := response[1].([]byte); !ok || !bytes.Equal(data, []byte{0xff, 1, 2, 3}) {
+		t.Fatalf("got %#v, want binary data", response[1])
+	}
+}
+
+func TestReconnectWithBackoff(t *testing.T) {
+	server := newTestServer(t)
+	connects := make(chan string, 4)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.On("echo", echo)
+		connects <- socket.ID()
+	})
+
+	var (
+		mu       sync.Mutex
+		attempts []time.Time
+	)
+	reconnected := make(chan int, 1)
+
+	delay := 20 * time.Millisecond
+	socket := connect(t, server.URL, &client.Options{
+		// CloseClientConnections does not close hijacked WebSocket connections
+		Transports:           []string{engineio.TransportPolling},
+		ReconnectionDelay:    delay,
+		ReconnectionDelayMax: time.Second,
+		RandomizationFactor:  -1,
+	})
+	first := <-connects
+	socket.Manager().OnReconnectAttempt(func(attempt int) {
+		mu.Lock()
+		attempts = append(attempts, time.Now())
+		n := len(attempts)
+		mu.Unlock()
+
+		// Let the third attempt succeed
+		if n == 3 {
+			server.down.Store(false)
+		}
+	})
+	socket.Manager().OnReconnect(func(attempt int) { reconnected <- attempt })
+	disconnects := make(chan string, 1)
+	socket.OnDisconnect(func(reason string) { disconnects <- reason })
+	reconnects := make(chan struct{}, 1)
+	socket.OnConnect(func() { reconnects <- struct{}{} })
+
+	lost := time.Now()
+	server.down.Store(true)
+	server.http.CloseClientConnections()
+
+	if reason := <-disconnects; reason != "transport error" && reason != "transport close" {
+		t.Fatalf("disconnected with %q, want the connection lost", reason)
+	}
+	select {
+	case attempt := <-reconnected:
+		if attempt != 3 {
+			t.Fatalf("reconnected at attempt %d, want 3", attempt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("not reconnected")
+	}
+
+	// Delays double after every failed attempt
+	mu.Lock()
+	defer mu.Unlock()
+	previous := lost
+	for i, at := range attempts {
+		if wait := at.Sub(previous); wait < delay<<i {
+			t.Fatalf("attempt %d after %v, want at least %v", i+1, wait, delay<<i)
+		}
+		previous = at
+	}
+
+	select {
+	case id := <-connects:
+		if id == first {
+			t.Fatal("reconnected with the same socket ID")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("socket not connected again")
+	}
+	<-reconnects
+	if response := emitWithAck(t, socket, "echo", "again"); len(response) != 1 || response[0] != "again" {
+		t.Fatalf("got %v after reconnecting", response)
+	}
+}
+
+func TestMultiplexing(t *testing.T) {
+	server := newTestServer(t)
+	for _, name := range []string{"/", "/chat", "/admin"} {
+		name := name
+		server.Of(name).OnConnect(func(socket *gosocketio.Socket) {
+			socket.On("whoami", func(args ...interface{}) {
+				args[len(args)-1].(func(...interface{}))(name, socket.Handshake().Auth["token"])
+			})
+		})
+	}
+
+	manager, err := client.NewManager(server.URL, &client.Options{
+		Auth: map[string]interface{}{"token": "shared"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(manager.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sockets := map[string]*client.Socket{}
+	for _, name := range []string{"/", "/chat", "/admin"} {
+		sockets[name] = manager.Socket(name)
+		if err := sockets[name].Connect(ctx); err != nil {
+			t.Fatalf("Connect(%s): %v", name, err)
+		}
+	}
+	if manager.Socket("/chat") != sockets["/chat"] {
+		t.Fatal("Socket returned a new socket for a known namespace")
+	}
+
+	for name, socket := range sockets {
+		response := emitWithAck(t, socket, "whoami")
+		if len(response) != 2 || response[0] != name || response[1] != "shared" {
+			t.Fatalf("%s answered %v", name, response)
+		}
+	}
+	if n := server.handshakes.Load(); n != 1 {
+		t.Fatalf("%d connections opened, want one shared by the sockets", n)
+	}
+
+	// Disconnecting one socket leaves the others connected
+	sockets["/chat"].Disconnect()
+	if response := emitWithAck(t, sockets["/admin"], "whoami"); response[0] != "/admin" {
+		t.Fatalf("/admin answered %v", response)
+	}
+}
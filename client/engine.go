@@ -0,0 +1,365 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ramory-l/gosocketio/engineio"
+)
+
+// ErrEngineClosed is returned when sending on a closed connection.
+var ErrEngineClosed = errors.New("connection closed")
+
+// Reasons passed to the close handlers of an engine
+const (
+	reasonForcedClose    = "forced close"
+	reasonTransportClose = "transport close"
+	reasonTransportError = "transport error"
+	reasonPingTimeout    = "ping timeout"
+	reasonParseError     = "parse error"
+)
+
+// engine is an Engine.IO client connection. It handles the handshake, the
+// heartbeat and the upgrade from HTTP long-polling to WebSocket.
+type engine struct {
+	url  *url.URL
+	opts *Options
+
+	sid          string
+	upgrades     []string
+	pingInterval time.Duration
+	pingTimeout  time.Duration
+
+	mu        sync.RWMutex
+	transport transport
+
+	// writeMu serializes writes, and is held while switching transports
+	writeMu sync.Mutex
+
+	onPacket func(*engineio.Packet)
+	onClose  func(reason string)
+
+	pingTimer *time.Timer
+	timerMu   sync.Mutex
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// dialEngine opens an Engine.IO connection with the first transport of the
+// options. The connection does not read packets until start is called.
+func dialEngine(ctx context.Context, u *url.URL, opts *Options) (*engine, error) {
+	e := &engine{
+		url:    u,
+		opts:   opts,
+		closed: make(chan struct{}),
+	}
+
+	var (
+		packets []*engineio.Packet
+		err     error
+	)
+
+	switch name := opts.Transports[0]; name {
+	case engineio.TransportWebSocket:
+		var t *websocketTransport
+		t, err = dialWebSocket(ctx, e.transportURL(name, ""), opts)
+		if err != nil {
+			return nil, err
+		}
+		e.transport = t
+
+		// Abort the read if the context is done first
+		stop := context.AfterFunc(ctx, t.close)
+		packets, err = t.receive()
+		if !stop() {
+			err = ctx.Err()
+		}
+
+	case engineio.TransportPolling:
+		t := newPollingTransport(e.transportURL(name, ""), opts)
+		e.transport = t
+		packets, err = t.poll(ctx)
+
+	default:
+		return nil, fmt.Errorf("unknown transport %q", name)
+	}
+
+	if err == nil {
+		err = e.handshake(packets)
+	}
+	if err != nil {
+		e.transport.close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// handshake reads the open packet sent by the server
+func (e *engine) handshake(packets []*engineio.Packet) error {
+	if len(packets) == 0 || packets[0].Type != engineio.PacketTypeOpen {
+		return fmt.Errorf("expected open packet")
+	}
+
+	var data engineio.HandshakeData
+	if err := json.Unmarshal(packets[0].Data, &data); err != nil {
+		return fmt.Errorf("invalid handshake: %w", err)
+	}
+	if data.SID == "" {
+		return fmt.Errorf("invalid handshake: missing sid")
+	}
+
+	e.sid = data.SID
+	e.upgrades = data.Upgrades
+	e.pingInterval = time.Duration(data.PingInterval) * time.Millisecond
+	e.pingTimeout = time.Duration(data.PingTimeout) * time.Millisecond
+
+	if t, ok := e.transport.(*pollingTransport); ok {
+		t.setSID(e.sid)
+	}
+
+	return nil
+}
+
+// transportURL returns the Engine.IO URL for the transport and session ID
+func (e *engine) transportURL(name, sid string) string {
+	u := *e.url
+
+	query := u.Query()
+	for key, values := range e.opts.Query {
+		query[key] = values
+	}
+	query.Set("EIO", "4")
+	query.Set("transport", name)
+	if sid != "" {
+		query.Set("sid", sid)
+	}
+	u.RawQuery = query.Encode()
+
+	if name == engineio.TransportWebSocket {
+		switch u.Scheme {
+		case "http":
+			u.Scheme = "ws"
+		case "https":
+			u.Scheme = "wss"
+		}
+	}
+
+	return u.String()
+}
+
+// start sets the handlers and starts reading packets. Packets are handled
+// in order on a single goroutine.
+func (e *engine) start(onPacket func(*engineio.Packet), onClose func(string)) {
+	e.onPacket = onPacket
+	e.onClose = onClose
+
+	e.resetPingTimeout()
+
+	t := e.currentTransport()
+	done := make(chan struct{})
+	go e.readLoop(t, done)
+
+	if polling, ok := t.(*pollingTransport); ok && e.canUpgrade() {
+		go e.upgrade(polling, done)
+	}
+}
+
+// canUpgrade reports whether the connection should be upgraded to WebSocket
+func (e *engine) canUpgrade() bool {
+	return !e.opts.DisableUpgrade &&
+		slices.Contains(e.upgrades, engineio.TransportWebSocket) &&
+		slices.Contains(e.opts.Transports, engineio.TransportWebSocket)
+}
+
+func (e *engine) currentTransport() transport {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.transport
+}
+
+func (e *engine) readLoop(t transport, done chan struct{}) {
+	defer close(done)
+
+	polling, _ := t.(*pollingTransport)
+
+	for {
+		packets, err := t.receive()
+		if err != nil {
+			switch {
+			case polling != nil && polling.paused.Load():
+				// The last poll after an upgrade may fail
+			case polling != nil:
+				e.close(reasonTransportError)
+			default:
+				e.close(reasonTransportClose)
+			}
+			return
+		}
+
+		for _, packet := range packets {
+			e.handlePacket(packet)
+		}
+
+		if polling != nil && polling.paused.Load() {
+			return
+		}
+
+		select {
+		case <-e.closed:
+			return
+		default:
+		}
+	}
+}
+
+func (e *engine) handlePacket(packet *engineio.Packet) {
+	switch packet.Type {
+	case engineio.PacketTypePing:
+		e.resetPingTimeout()
+		e.send([]*engineio.Packet{{Type: engineio.PacketTypePong}})
+	case engineio.PacketTypeMessage:
+		e.onPacket(packet)
+	case engineio.PacketTypeClose:
+		e.close(reasonTransportClose)
+	}
+}
+
+// upgrade probes a WebSocket connection and, if the probe succeeds, moves
+// the connection to it. Polling continues if the probe fails.
+func (e *engine) upgrade(polling *pollingTransport, pollDone chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.pingTimeout)
+	ws, err := dialWebSocket(ctx, e.transportURL(engineio.TransportWebSocket, e.sid), e.opts)
+	cancel()
+	if err != nil {
+		return
+	}
+
+	// Close both transports if the connection closes during the upgrade
+	upgrading := make(chan struct{})
+	defer close(upgrading)
+	go func() {
+		select {
+		case <-e.closed:
+			polling.close()
+			ws.close()
+		case <-upgrading:
+		}
+	}()
+
+	ws.conn.SetReadDeadline(time.Now().Add(e.pingTimeout))
+
+	probe := &engineio.Packet{Type: engineio.PacketTypePing, Data: []byte("probe")}
+	if err := ws.send([]*engineio.Packet{probe}); err != nil {
+		ws.close()
+		return
+	}
+
+	packets, err := ws.receive()
+	if err != nil || packets[0].Type != engineio.PacketTypePong || string(packets[0].Data) != "probe" {
+		ws.close()
+		return
+	}
+	ws.conn.SetReadDeadline(time.Time{})
+
+	// Send the upgrade packet with writes held, so that every packet sent
+	// after it goes over the WebSocket connection
+	e.writeMu.Lock()
+	polling.paused.Store(true)
+	err = ws.send([]*engineio.Packet{{Type: engineio.PacketTypeUpgrade}})
+	if err == nil {
+		e.mu.Lock()
+		e.transport = ws
+		e.mu.Unlock()
+	}
+	e.writeMu.Unlock()
+
+	if err != nil {
+		ws.close()
+		e.close(reasonTransportError)
+		return
+	}
+
+	// The server answers the pending poll once upgraded. Packets it carries
+	// are handled before the ones received over WebSocket.
+	<-pollDone
+	polling.close()
+
+	select {
+	case <-e.closed:
+		ws.close()
+		return
+	default:
+	}
+
+	go e.readLoop(ws, make(chan struct{}))
+}
+
+// send sends packets to the server, in order
+func (e *engine) send(packets []*engineio.Packet) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	select {
+	case <-e.closed:
+		return ErrEngineClosed
+	default:
+	}
+
+	if err := e.currentTransport().send(packets); err != nil {
+		go e.close(reasonTransportError)
+		return err
+	}
+	return nil
+}
+
+// resetPingTimeout closes the connection if the server does not ping again
+// within the ping interval and timeout
+func (e *engine) resetPingTimeout() {
+	e.timerMu.Lock()
+	defer e.timerMu.Unlock()
+
+	select {
+	case <-e.closed:
+		return
+	default:
+	}
+
+	if e.pingTimer != nil {
+		e.pingTimer.Stop()
+	}
+	e.pingTimer = time.AfterFunc(e.pingInterval+e.pingTimeout, func() {
+		e.close(reasonPingTimeout)
+	})
+}
+
+// close closes the connection and calls the close handler once. The server
+// is notified when the client closes the connection on purpose.
+func (e *engine) close(reason string) {
+	e.closeOnce.Do(func() {
+		if reason == reasonForcedClose {
+			e.send([]*engineio.Packet{{Type: engineio.PacketTypeClose}})
+		}
+
+		close(e.closed)
+
+		e.timerMu.Lock()
+		if e.pingTimer != nil {
+			e.pingTimer.Stop()
+		}
+		e.timerMu.Unlock()
+
+		e.currentTransport().close()
+
+		if e.onClose != nil {
+			e.onClose(reason)
+		}
+	})
+}
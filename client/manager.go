@@ -0,0 +1,527 @@
+// Package client provides a Socket.IO v4 client, for services, command-line
+// tools and tests that talk to a Socket.IO server from Go.
+//
+// A Manager holds one Engine.IO connection, over HTTP long-polling upgraded to
+// WebSocket or over WebSocket only, and multiplexes the sockets of several
+// namespaces on it. When the connection is lost, the manager reconnects with
+// exponential backoff and jitter, and reconnects every socket that was not
+// disconnected on purpose.
+//
+// Example:
+//
+//	socket, err := client.Connect(ctx, "http://localhost:3000/chat", &client.Options{
+//	    Auth: map[string]interface{}{"token": "abc"},
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer socket.Disconnect()
+//
+//	socket.On("message", func(data ...interface{}) {
+//	    log.Printf("Received: %v", data)
+//	})
+//
+//	response, err := socket.EmitWithAckContext(ctx, "join", "lobby")
+//
+// Packets are encoded with the gosocketio packet types and parsers, so the
+// client supports binary data and any parser the server is configured with.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/engineio"
+)
+
+var (
+	// ErrManagerClosed is returned by Socket.Connect when the manager is
+	// closed before the socket connects.
+	ErrManagerClosed = errors.New("manager closed")
+
+	// ErrReconnectFailed is returned by Socket.Connect when the manager gives
+	// up after Options.ReconnectionAttempts attempts.
+	ErrReconnectFailed = errors.New("reconnection failed")
+)
+
+// Options configures a Manager.
+type Options struct {
+	// Path is the path the server handles (default: "/socket.io/")
+	Path string
+
+	// Transports lists the transports to use (default: polling, then
+	// websocket). The connection is opened with the first one and upgraded
+	// to WebSocket if it is listed and the server allows it.
+	Transports []string
+
+	// DisableUpgrade keeps the connection on the first transport.
+	DisableUpgrade bool
+
+	// Query holds query parameters added to every request.
+	Query url.Values
+
+	// Header holds headers added to every request.
+	Header http.Header
+
+	// Auth is sent to the server when a socket connects to a namespace.
+	Auth map[string]interface{}
+
+	// Parser encodes and decodes packets (default: gosocketio.JSONParser).
+	// It must be compatible with the parser of the server.
+	Parser gosocketio.Parser
+
+	// Timeout is how long opening a connection may take (default: 20s)
+	Timeout time.Duration
+
+	// DisableReconnection stops the manager from reconnecting when the
+	// connection is lost or cannot be opened.
+	DisableReconnection bool
+
+	// ReconnectionAttempts is the number of attempts before giving up, or
+	// 0 to try forever.
+	ReconnectionAttempts int
+
+	// ReconnectionDelay is the delay before the first attempt, doubled after
+	// every failed attempt (default: 1s)
+	ReconnectionDelay time.Duration
+
+	// ReconnectionDelayMax is the maximum delay between attempts (default: 5s)
+	ReconnectionDelayMax time.Duration
+
+	// RandomizationFactor randomizes every delay by up to this fraction of
+	// it, so that clients do not all reconnect at once (default: 0.5). A
+	// negative value disables it.
+	RandomizationFactor float64
+
+	// HTTPClient sends the long-polling requests (default: http.DefaultClient)
+	HTTPClient *http.Client
+
+	// Dialer opens WebSocket connections (default: websocket.DefaultDialer)
+	Dialer *websocket.Dialer
+}
+
+// withDefaults returns a copy of the options with defaults applied
+func (o *Options) withDefaults() Options {
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+
+	if opts.Path == "" {
+		opts.Path = "/socket.io/"
+	}
+	if len(opts.Transports) == 0 {
+		opts.Transports = []string{engineio.TransportPolling, engineio.TransportWebSocket}
+	}
+	if opts.Parser == nil {
+		opts.Parser = gosocketio.JSONParser{}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 20 * time.Second
+	}
+	if opts.ReconnectionDelay == 0 {
+		opts.ReconnectionDelay = time.Second
+	}
+	if opts.ReconnectionDelayMax == 0 {
+		opts.ReconnectionDelayMax = 5 * time.Second
+	}
+	if opts.RandomizationFactor == 0 {
+		opts.RandomizationFactor = 0.5
+	}
+
+	return opts
+}
+
+// Manager manages the connection to a server, shared by the sockets of every
+// namespace.
+type Manager struct {
+	url  *url.URL
+	opts Options
+
+	mu      sync.Mutex
+	engine  *engine
+	sockets map[string]*Socket
+	opening bool
+
+	// skipReconnect is set when the manager is closed on purpose
+	skipReconnect bool
+	reconnecting  bool
+	stopReconnect chan struct{}
+
+	handlersMu         sync.RWMutex
+	onReconnectAttempt []func(attempt int)
+	onReconnect        []func(attempt int)
+	onReconnectFailed  []func()
+}
+
+// NewManager creates a manager for the server at rawURL, such as
+// "http://localhost:3000". The connection is opened when a socket connects.
+func NewManager(rawURL string, opts *Options) (*Manager, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		opts:    opts.withDefaults(),
+		sockets: make(map[string]*Socket),
+	}
+
+	u.Path = m.opts.Path
+	m.url = u
+
+	return m, nil
+}
+
+// Connect connects to the namespace in the path of rawURL ("/" if empty),
+// such as "http://localhost:3000/chat", with a new manager.
+//
+// It blocks until the socket is connected, the server rejects it or ctx is
+// done. The manager is closed if the socket does not connect.
+func Connect(ctx context.Context, rawURL string, opts *Options) (*Socket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := u.Path
+	u.Path = ""
+
+	manager, err := NewManager(u.String(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	socket := manager.Socket(namespace)
+	if err := socket.Connect(ctx); err != nil {
+		manager.Close()
+		return nil, err
+	}
+
+	return socket, nil
+}
+
+// Socket returns the socket for the namespace, creating it if needed. The
+// socket is not connected until Socket.Connect is called.
+func (m *Manager) Socket(namespace string) *Socket {
+	if !strings.HasPrefix(namespace, "/") {
+		namespace = "/" + namespace
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	socket, ok := m.sockets[namespace]
+	if !ok {
+		socket = newSocket(m, namespace)
+		m.sockets[namespace] = socket
+	}
+	return socket
+}
+
+// OnReconnectAttempt registers a handler called before every reconnection
+// attempt, starting at 1.
+func (m *Manager) OnReconnectAttempt(handler func(attempt int)) {
+	m.handlersMu.Lock()
+	m.onReconnectAttempt = append(m.onReconnectAttempt, handler)
+	m.handlersMu.Unlock()
+}
+
+// OnReconnect registers a handler called when the connection is reopened
+// after the given number of attempts.
+func (m *Manager) OnReconnect(handler func(attempt int)) {
+	m.handlersMu.Lock()
+	m.onReconnect = append(m.onReconnect, handler)
+	m.handlersMu.Unlock()
+}
+
+// OnReconnectFailed registers a handler called when the manager gives up
+// after Options.ReconnectionAttempts attempts.
+func (m *Manager) OnReconnectFailed(handler func()) {
+	m.handlersMu.Lock()
+	m.onReconnectFailed = append(m.onReconnectFailed, handler)
+	m.handlersMu.Unlock()
+}
+
+// Close closes the connection without reconnecting. Sockets are
+// disconnected with the "forced close" reason.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.skipReconnect = true
+	if m.reconnecting {
+		close(m.stopReconnect)
+		m.reconnecting = false
+	}
+	e := m.engine
+	sockets := m.socketList()
+	m.mu.Unlock()
+
+	if e != nil {
+		e.close(reasonForcedClose)
+	}
+
+	for _, socket := range sockets {
+		socket.fail(ErrManagerClosed)
+	}
+}
+
+// connectSocket sends the CONNECT packet of the socket, opening the
+// connection first if needed
+func (m *Manager) connectSocket(socket *Socket) {
+	m.mu.Lock()
+	m.skipReconnect = false
+	e := m.engine
+	open := e == nil && !m.opening && !m.reconnecting
+	if open {
+		m.opening = true
+	}
+	m.mu.Unlock()
+
+	if e != nil {
+		socket.sendConnect(e)
+		return
+	}
+
+	if open {
+		go func() {
+			if err := m.open(); err != nil {
+				m.connectError(err, m.opts.DisableReconnection)
+				m.reconnect()
+			}
+		}()
+	}
+}
+
+// destroy closes the connection once no socket uses it
+func (m *Manager) destroy() {
+	m.mu.Lock()
+	for _, socket := range m.sockets {
+		if socket.isActive() {
+			m.mu.Unlock()
+			return
+		}
+	}
+	m.mu.Unlock()
+
+	m.Close()
+}
+
+// open opens the connection and connects the active sockets
+func (m *Manager) open() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.Timeout)
+	e, err := dialEngine(ctx, m.url, &m.opts)
+	cancel()
+
+	m.mu.Lock()
+	m.opening = false
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	if m.skipReconnect {
+		m.mu.Unlock()
+		e.close(reasonForcedClose)
+		return ErrManagerClosed
+	}
+	m.engine = e
+	m.reconnecting = false
+	sockets := m.socketList()
+	m.mu.Unlock()
+
+	decoder := m.opts.Parser.NewDecoder()
+	e.start(func(packet *engineio.Packet) {
+		m.onEnginePacket(e, decoder, packet)
+	}, func(reason string) {
+		m.onEngineClose(e, reason)
+	})
+
+	for _, socket := range sockets {
+		socket.sendConnect(e)
+	}
+
+	return nil
+}
+
+// socketList returns the sockets of the manager. m.mu must be held.
+func (m *Manager) socketList() []*Socket {
+	sockets := make([]*Socket, 0, len(m.sockets))
+	for _, socket := range m.sockets {
+		sockets = append(sockets, socket)
+	}
+	return sockets
+}
+
+func (m *Manager) onEnginePacket(e *engine, decoder gosocketio.Decoder, p *engineio.Packet) {
+	packet, err := decoder.Add(gosocketio.Frame{Data: p.Data, Binary: p.Binary})
+	if err != nil {
+		e.close(reasonParseError)
+		return
+	}
+	if packet == nil {
+		return
+	}
+	if packet.Data, err = packet.DecodedData(); err != nil {
+		e.close(reasonParseError)
+		return
+	}
+
+	m.mu.Lock()
+	socket, ok := m.sockets[packet.Namespace]
+	m.mu.Unlock()
+
+	if ok {
+		socket.onPacket(packet)
+	}
+}
+
+func (m *Manager) onEngineClose(e *engine, reason string) {
+	m.mu.Lock()
+	if m.engine != e {
+		m.mu.Unlock()
+		return
+	}
+	m.engine = nil
+	sockets := m.socketList()
+	m.mu.Unlock()
+
+	for _, socket := range sockets {
+		connected := socket.Connected()
+		socket.onClose(reason)
+
+		// Without reconnection, a socket waiting to connect never will
+		if !connected && m.opts.DisableReconnection {
+			socket.connectError(fmt.Errorf("%w: %s", ErrEngineClosed, reason), true)
+		}
+	}
+
+	m.reconnect()
+}
+
+// connectError reports an error opening the connection to the active
+// sockets. If final, their pending Connect calls return it.
+func (m *Manager) connectError(err error, final bool) {
+	m.mu.Lock()
+	sockets := m.socketList()
+	m.mu.Unlock()
+
+	for _, socket := range sockets {
+		socket.connectError(err, final)
+	}
+}
+
+// reconnect starts reconnecting in the background, unless reconnection is
+// disabled or the manager was closed
+func (m *Manager) reconnect() {
+	m.mu.Lock()
+	if m.opts.DisableReconnection || m.skipReconnect || m.reconnecting {
+		m.mu.Unlock()
+		return
+	}
+	m.reconnecting = true
+	stop := make(chan struct{})
+	m.stopReconnect = stop
+	m.mu.Unlock()
+
+	go m.reconnectLoop(stop)
+}
+
+func (m *Manager) reconnectLoop(stop chan struct{}) {
+	for attempt := 1; ; attempt++ {
+		if max := m.opts.ReconnectionAttempts; max > 0 && attempt > max {
+			m.mu.Lock()
+			m.reconnecting = false
+			m.mu.Unlock()
+
+			m.handlersMu.RLock()
+			handlers := m.onReconnectFailed
+			m.handlersMu.RUnlock()
+			for _, handler := range handlers {
+				handler()
+			}
+
+			m.connectError(ErrReconnectFailed, true)
+			return
+		}
+
+		timer := time.NewTimer(m.backoff(attempt - 1))
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return
+		}
+
+		m.handlersMu.RLock()
+		handlers := m.onReconnectAttempt
+		m.handlersMu.RUnlock()
+		for _, handler := range handlers {
+			handler(attempt)
+		}
+
+		err := m.open()
+		if err == nil {
+			m.handlersMu.RLock()
+			handlers := m.onReconnect
+			m.handlersMu.RUnlock()
+			for _, handler := range handlers {
+				handler(attempt)
+			}
+			return
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		m.connectError(err, false)
+	}
+}
+
+// backoff returns the delay before a reconnection attempt: the initial delay
+// doubled for every previous attempt, randomized and capped
+func (m *Manager) backoff(attempts int) time.Duration {
+	delay := float64(m.opts.ReconnectionDelay) * math.Pow(2, float64(attempts))
+
+	if factor := m.opts.RandomizationFactor; factor > 0 {
+		deviation := rand.Float64() * factor * delay
+		if rand.IntN(2) == 0 {
+			delay -= deviation
+		} else {
+			delay += deviation
+		}
+	}
+
+	return time.Duration(math.Min(delay, float64(m.opts.ReconnectionDelayMax)))
+}
+
+// send encodes a packet and sends it on the connection
+func (m *Manager) send(e *engine, packet *gosocketio.Packet) error {
+	frames, err := m.opts.Parser.Encode(packet)
+	if err != nil {
+		return err
+	}
+
+	packets := make([]*engineio.Packet, 0, len(frames))
+	for _, frame := range frames {
+		packets = append(packets, &engineio.Packet{
+			Type:   engineio.PacketTypeMessage,
+			Data:   frame.Data,
+			Binary: frame.Binary,
+		})
+	}
+
+	return e.send(packets)
+}
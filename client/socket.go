@@ -0,0 +1,602 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ramory-l/gosocketio"
+)
+
+// ErrNotConnected is returned when emitting on a socket that is not connected.
+var ErrNotConnected = errors.New("socket is not connected")
+
+// ackCallback is called with the acknowledgment data, or with an error if the
+// socket disconnects first
+type ackCallback func(args []interface{}, err error)
+
+// Socket is a connection to a namespace, created with Manager.Socket or
+// Connect.
+//
+// Handlers registered with On, OnAny, OnConnect, OnDisconnect and
+// OnConnectError are called one at a time, in the order the packets were
+// received, on a goroutine of the socket.
+type Socket struct {
+	manager   *Manager
+	namespace string
+
+	mu        sync.RWMutex
+	id        string
+	pid       string
+	offset    string
+	connected bool
+	recovered bool
+
+	// active is set while the socket should be connected, including while
+	// the manager reconnects
+	active bool
+
+	// engine is the connection the CONNECT packet was sent on
+	engine  *engine
+	waiters []chan error
+
+	handlersMu     sync.RWMutex
+	handlers       map[string][]gosocketio.EventHandler
+	anyHandlers    []gosocketio.AnyHandler
+	onConnect      []func()
+	onDisconnect   []func(string)
+	onConnectError []func(error)
+
+	ackID       atomic.Int64
+	ackHandlers sync.Map
+
+	events eventQueue
+}
+
+func newSocket(manager *Manager, namespace string) *Socket {
+	return &Socket{
+		manager:   manager,
+		namespace: namespace,
+		handlers:  make(map[string][]gosocketio.EventHandler),
+	}
+}
+
+// ID returns the socket ID assigned by the server, or "" while disconnected.
+func (s *Socket) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// Namespace returns the namespace of the socket.
+func (s *Socket) Namespace() string {
+	return s.namespace
+}
+
+// Manager returns the manager of the socket.
+func (s *Socket) Manager() *Manager {
+	return s.manager
+}
+
+// Connected reports whether the socket is connected.
+func (s *Socket) Connected() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.connected
+}
+
+// Recovered reports whether the server restored the state of the socket on
+// its last connection. See gosocketio.RecoveryConfig.
+func (s *Socket) Recovered() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recovered
+}
+
+// Connect connects the socket to its namespace, opening the connection of
+// the manager if needed.
+//
+// It blocks until the socket is connected or ctx is done. It returns a
+// *gosocketio.ConnectError if the server rejects the socket, and the error
+// opening the connection, or ErrEngineClosed if it closes before the socket
+// connects, if Options.DisableReconnection is set. Otherwise the
+// manager keeps reconnecting in the background until the socket connects or
+// Disconnect is called, even after ctx is done.
+func (s *Socket) Connect(ctx context.Context) error {
+	done := make(chan error, 1)
+
+	s.mu.Lock()
+	if s.connected {
+		s.mu.Unlock()
+		return nil
+	}
+	s.active = true
+	s.waiters = append(s.waiters, done)
+	s.mu.Unlock()
+
+	s.manager.connectSocket(s)
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Disconnect disconnects the socket from its namespace. The connection of the
+// manager is closed once no socket uses it.
+func (s *Socket) Disconnect() {
+	s.mu.Lock()
+	connected, e := s.connected, s.engine
+	s.active = false
+	s.mu.Unlock()
+
+	if connected {
+		s.manager.send(e, &gosocketio.Packet{
+			Type:      gosocketio.PacketTypeDisconnect,
+			Namespace: s.namespace,
+		})
+	}
+
+	s.onClose("io client disconnect")
+	s.fail(gosocketio.ErrSocketDisconnected)
+	s.manager.destroy()
+}
+
+// Emit sends an event to the server.
+//
+// Example:
+//
+//	socket.Emit("message", "Hello, server!")
+func (s *Socket) Emit(event string, data ...interface{}) error {
+	args := make([]interface{}, 0, len(data)+1)
+	args = append(args, event)
+	args = append(args, data...)
+
+	return s.send(&gosocketio.Packet{
+		Type:      gosocketio.PacketTypeEvent,
+		Namespace: s.namespace,
+		Data:      args,
+	})
+}
+
+// EmitWithAck sends an event to the server and calls ack with its
+// acknowledgment. The handler is not called if the socket disconnects first.
+//
+// Example:
+//
+//	socket.EmitWithAck("question", func(response ...interface{}) {
+//	    log.Printf("Server answered: %v", response)
+//	}, "What's your name?")
+func (s *Socket) EmitWithAck(event string, ack gosocketio.AckHandler, data ...interface{}) error {
+	_, err := s.emitWithAck(event, data, func(args []interface{}, err error) {
+		if err == nil {
+			ack(args...)
+		}
+	})
+	return err
+}
+
+// EmitWithAckContext sends an event to the server and waits for its
+// acknowledgment.
+//
+// It returns the acknowledgment data, or an error if the context is done before
+// the server responds (ctx.Err()) or the socket disconnects
+// (gosocketio.ErrSocketDisconnected).
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//
+//	response, err := socket.EmitWithAckContext(ctx, "question", "What's your name?")
+func (s *Socket) EmitWithAckContext(ctx context.Context, event string, data ...interface{}) ([]interface{}, error) {
+	type result struct {
+		args []interface{}
+		err  error
+	}
+
+	done := make(chan result, 1)
+	id, err := s.emitWithAck(event, data, func(args []interface{}, err error) {
+		done <- result{args, err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-done:
+		return r.args, r.err
+	case <-ctx.Done():
+		s.ackHandlers.Delete(id)
+		return nil, ctx.Err()
+	}
+}
+
+// emitWithAck sends an event with a new ack ID and registers the callback for
+// its acknowledgment.
+func (s *Socket) emitWithAck(event string, data []interface{}, callback ackCallback) (int, error) {
+	args := make([]interface{}, 0, len(data)+1)
+	args = append(args, event)
+	args = append(args, data...)
+
+	id := int(s.ackID.Add(1))
+	s.ackHandlers.Store(id, callback)
+
+	err := s.send(&gosocketio.Packet{
+		Type:      gosocketio.PacketTypeEvent,
+		Namespace: s.namespace,
+		Data:      args,
+		ID:        &id,
+	})
+	if err != nil {
+		s.ackHandlers.Delete(id)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// On registers an event handler for the specified event.
+//
+// If the server requests an acknowledgment, the last argument passed to the
+// handler is a func(...interface{}) sending it.
+//
+// Example:
+//
+//	socket.On("question", func(data ...interface{}) {
+//	    if ack, ok := data[len(data)-1].(func(...interface{})); ok {
+//	        ack("42")
+//	    }
+//	})
+func (s *Socket) On(event string, handler gosocketio.EventHandler) {
+	s.handlersMu.Lock()
+	s.handlers[event] = append(s.handlers[event], handler)
+	s.handlersMu.Unlock()
+}
+
+// Off removes all handlers for the specified event.
+func (s *Socket) Off(event string) {
+	s.handlersMu.Lock()
+	delete(s.handlers, event)
+	s.handlersMu.Unlock()
+}
+
+// OnAny registers a handler called for every event received, before the
+// handlers registered with On.
+func (s *Socket) OnAny(handler gosocketio.AnyHandler) {
+	s.handlersMu.Lock()
+	s.anyHandlers = append(s.anyHandlers, handler)
+	s.handlersMu.Unlock()
+}
+
+// OffAny removes all handlers registered with OnAny.
+func (s *Socket) OffAny() {
+	s.handlersMu.Lock()
+	s.anyHandlers = nil
+	s.handlersMu.Unlock()
+}
+
+// OnConnect registers a handler called every time the socket connects,
+// including after a reconnection.
+func (s *Socket) OnConnect(handler func()) {
+	s.handlersMu.Lock()
+	s.onConnect = append(s.onConnect, handler)
+	s.handlersMu.Unlock()
+}
+
+// OnDisconnect registers a handler called when the socket disconnects, with
+// the reason: "io server disconnect", "io client disconnect", "forced close",
+// "ping timeout", "transport close", "transport error" or "parse error".
+//
+// The manager reconnects the socket after the last four.
+func (s *Socket) OnDisconnect(handler func(reason string)) {
+	s.handlersMu.Lock()
+	s.onDisconnect = append(s.onDisconnect, handler)
+	s.handlersMu.Unlock()
+}
+
+// OnConnectError registers a handler called when the socket fails to
+// connect: with a *gosocketio.ConnectError if the server rejects it, or with
+// the error opening the connection.
+func (s *Socket) OnConnectError(handler func(err error)) {
+	s.handlersMu.Lock()
+	s.onConnectError = append(s.onConnectError, handler)
+	s.handlersMu.Unlock()
+}
+
+// send sends a packet if the socket is connected
+func (s *Socket) send(packet *gosocketio.Packet) error {
+	s.mu.RLock()
+	connected, e := s.connected, s.engine
+	s.mu.RUnlock()
+
+	if !connected {
+		return ErrNotConnected
+	}
+	return s.manager.send(e, packet)
+}
+
+func (s *Socket) isActive() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+// sendConnect sends the CONNECT packet on the connection, once. The private
+// session ID and the offset of the last packet are sent along with the auth
+// payload so that the server can recover the state of the socket.
+func (s *Socket) sendConnect(e *engine) {
+	s.mu.Lock()
+	if !s.active || s.engine == e {
+		s.mu.Unlock()
+		return
+	}
+	s.engine = e
+
+	var auth map[string]interface{}
+	if s.manager.opts.Auth != nil || s.pid != "" {
+		auth = maps.Clone(s.manager.opts.Auth)
+		if auth == nil {
+			auth = make(map[string]interface{})
+		}
+	}
+	if s.pid != "" {
+		auth["pid"] = s.pid
+		if s.offset != "" {
+			auth["offset"] = s.offset
+		}
+	}
+	s.mu.Unlock()
+
+	packet := &gosocketio.Packet{
+		Type:      gosocketio.PacketTypeConnect,
+		Namespace: s.namespace,
+	}
+	if auth != nil {
+		packet.Data = auth
+	}
+
+	s.manager.send(e, packet)
+}
+
+func (s *Socket) onPacket(packet *gosocketio.Packet) {
+	switch packet.Type {
+	case gosocketio.PacketTypeConnect:
+		s.onConnectPacket(packet)
+	case gosocketio.PacketTypeConnectError:
+		s.onConnectErrorPacket(packet)
+	case gosocketio.PacketTypeEvent, gosocketio.PacketTypeBinaryEvent:
+		s.onEvent(packet)
+	case gosocketio.PacketTypeAck, gosocketio.PacketTypeBinaryAck:
+		s.onAck(packet)
+	case gosocketio.PacketTypeDisconnect:
+		s.mu.Lock()
+		s.active = false
+		s.mu.Unlock()
+
+		s.onClose("io server disconnect")
+		s.manager.destroy()
+	}
+}
+
+func (s *Socket) onConnectPacket(packet *gosocketio.Packet) {
+	data, _ := packet.Data.(map[string]interface{})
+	sid, _ := data["sid"].(string)
+	pid, _ := data["pid"].(string)
+
+	s.mu.Lock()
+	if !s.active {
+		s.mu.Unlock()
+		return
+	}
+	s.id = sid
+	s.connected = true
+	s.recovered = pid != "" && pid == s.pid
+	s.pid = pid
+	waiters := s.waiters
+	s.waiters = nil
+	s.mu.Unlock()
+
+	for _, waiter := range waiters {
+		waiter <- nil
+	}
+
+	s.handlersMu.RLock()
+	handlers := s.onConnect
+	s.handlersMu.RUnlock()
+
+	s.events.push(func() {
+		for _, handler := range handlers {
+			handler()
+		}
+	})
+}
+
+func (s *Socket) onConnectErrorPacket(packet *gosocketio.Packet) {
+	data, _ := packet.Data.(map[string]interface{})
+	message, _ := data["message"].(string)
+
+	s.connectError(&gosocketio.ConnectError{Message: message, Data: data["data"]}, true)
+	s.manager.destroy()
+}
+
+// connectError calls the connect error handlers. If final, the socket is
+// no longer active and pending Connect calls return the error.
+func (s *Socket) connectError(err error, final bool) {
+	if !s.isActive() {
+		return
+	}
+
+	if final {
+		s.fail(err)
+	}
+
+	s.handlersMu.RLock()
+	handlers := s.onConnectError
+	s.handlersMu.RUnlock()
+
+	s.events.push(func() {
+		for _, handler := range handlers {
+			handler(err)
+		}
+	})
+}
+
+// fail deactivates the socket and returns err from pending Connect calls
+func (s *Socket) fail(err error) {
+	s.mu.Lock()
+	s.active = false
+	if !s.connected {
+		s.engine = nil
+	}
+	waiters := s.waiters
+	s.waiters = nil
+	s.mu.Unlock()
+
+	for _, waiter := range waiters {
+		waiter <- err
+	}
+}
+
+func (s *Socket) onEvent(packet *gosocketio.Packet) {
+	dataArray, ok := packet.Data.([]interface{})
+	if !ok || len(dataArray) == 0 {
+		return
+	}
+
+	event, ok := dataArray[0].(string)
+	if !ok {
+		return
+	}
+
+	args := dataArray[1:]
+
+	// Keep the offset appended by servers with connection state recovery
+	s.mu.Lock()
+	if s.pid != "" && len(args) > 0 {
+		if offset, ok := args[len(args)-1].(string); ok {
+			s.offset = offset
+		}
+	}
+	s.mu.Unlock()
+
+	if packet.ID != nil {
+		var sent atomic.Bool
+		ackFunc := func(ackData ...interface{}) {
+			// Only the first acknowledgment is sent
+			if !sent.CompareAndSwap(false, true) {
+				return
+			}
+			s.send(&gosocketio.Packet{
+				Type:      gosocketio.PacketTypeAck,
+				Namespace: s.namespace,
+				Data:      ackData,
+				ID:        packet.ID,
+			})
+		}
+		args = append(args, ackFunc)
+	}
+
+	s.handlersMu.RLock()
+	anyHandlers := s.anyHandlers
+	handlers := s.handlers[event]
+	s.handlersMu.RUnlock()
+
+	s.events.push(func() {
+		for _, handler := range anyHandlers {
+			handler(event, args...)
+		}
+		for _, handler := range handlers {
+			handler(args...)
+		}
+	})
+}
+
+func (s *Socket) onAck(packet *gosocketio.Packet) {
+	if packet.ID == nil {
+		return
+	}
+
+	val, ok := s.ackHandlers.LoadAndDelete(*packet.ID)
+	if !ok {
+		return
+	}
+
+	var args []interface{}
+	if dataArray, ok := packet.Data.([]interface{}); ok {
+		args = dataArray
+	}
+
+	go val.(ackCallback)(args, nil)
+}
+
+// onClose marks the socket as disconnected, failing pending acknowledgments
+// and calling the disconnect handlers if it was connected
+func (s *Socket) onClose(reason string) {
+	s.mu.Lock()
+	s.engine = nil
+	if !s.connected {
+		s.mu.Unlock()
+		return
+	}
+	s.connected = false
+	s.id = ""
+	s.mu.Unlock()
+
+	s.ackHandlers.Range(func(key, val interface{}) bool {
+		if _, ok := s.ackHandlers.LoadAndDelete(key); ok {
+			go val.(ackCallback)(nil, gosocketio.ErrSocketDisconnected)
+		}
+		return true
+	})
+
+	s.handlersMu.RLock()
+	handlers := s.onDisconnect
+	s.handlersMu.RUnlock()
+
+	s.events.push(func() {
+		for _, handler := range handlers {
+			handler(reason)
+		}
+	})
+}
+
+// eventQueue runs functions one at a time in the order they are pushed. Its
+// goroutine exits when the queue is empty.
+type eventQueue struct {
+	mu      sync.Mutex
+	fns     []func()
+	running bool
+}
+
+func (q *eventQueue) push(fn func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.fns = append(q.fns, fn)
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+}
+
+func (q *eventQueue) run() {
+	for {
+		q.mu.Lock()
+		if len(q.fns) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		fn := q.fns[0]
+		q.fns[0] = nil
+		q.fns = q.fns[1:]
+		q.mu.Unlock()
+
+		fn()
+	}
+}
@@ -0,0 +1,231 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ramory-l/gosocketio/engineio"
+)
+
+// transport carries Engine.IO packets to and from the server
+type transport interface {
+	// name returns the transport name sent in the query string
+	name() string
+
+	// send sends packets to the server, in order
+	send(packets []*engineio.Packet) error
+
+	// receive blocks until packets are received from the server
+	receive() ([]*engineio.Packet, error)
+
+	// close closes the transport, unblocking receive
+	close()
+}
+
+// websocketTransport sends one packet per WebSocket frame
+type websocketTransport struct {
+	conn *websocket.Conn
+}
+
+func dialWebSocket(ctx context.Context, rawURL string, opts *Options) (*websocketTransport, error) {
+	dialer := opts.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, rawURL, opts.Header)
+	if err != nil {
+		if resp != nil {
+			return nil, responseError(resp)
+		}
+		return nil, err
+	}
+
+	return &websocketTransport{conn: conn}, nil
+}
+
+func (t *websocketTransport) name() string {
+	return engineio.TransportWebSocket
+}
+
+func (t *websocketTransport) send(packets []*engineio.Packet) error {
+	for _, packet := range packets {
+		var err error
+		if packet.Binary {
+			err = t.conn.WriteMessage(websocket.BinaryMessage, packet.Data)
+		} else {
+			err = t.conn.WriteMessage(websocket.TextMessage, packet.Encode())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *websocketTransport) receive() ([]*engineio.Packet, error) {
+	messageType, data, err := t.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	if messageType == websocket.BinaryMessage {
+		return []*engineio.Packet{{Type: engineio.PacketTypeMessage, Data: data, Binary: true}}, nil
+	}
+
+	packet, err := engineio.DecodePacket(data)
+	if err != nil {
+		return nil, err
+	}
+	return []*engineio.Packet{packet}, nil
+}
+
+func (t *websocketTransport) close() {
+	t.conn.Close()
+}
+
+// pollingTransport sends packets with POST requests and receives them with
+// long-polling GET requests
+type pollingTransport struct {
+	client *http.Client
+	url    string
+	header http.Header
+
+	// ctx is canceled on close to abort pending requests
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// paused is set once the connection is upgraded to WebSocket. Errors
+	// from the last poll are then ignored.
+	paused atomic.Bool
+}
+
+func newPollingTransport(rawURL string, opts *Options) *pollingTransport {
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &pollingTransport{
+		client: client,
+		url:    rawURL,
+		header: opts.Header,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (t *pollingTransport) name() string {
+	return engineio.TransportPolling
+}
+
+func (t *pollingTransport) send(packets []*engineio.Packet) error {
+	_, err := t.do(t.ctx, http.MethodPost, engineio.EncodePayload(packets))
+	return err
+}
+
+func (t *pollingTransport) receive() ([]*engineio.Packet, error) {
+	return t.poll(t.ctx)
+}
+
+// poll sends a GET request and decodes the payload of its response
+func (t *pollingTransport) poll(ctx context.Context) ([]*engineio.Packet, error) {
+	body, err := t.do(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	return engineio.DecodePayload(body)
+}
+
+func (t *pollingTransport) do(ctx context.Context, method string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "text/plain; charset=UTF-8")
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (t *pollingTransport) close() {
+	t.cancel()
+}
+
+// setSID adds the session ID to the request URL once the handshake is done
+func (t *pollingTransport) setSID(sid string) {
+	t.url = withQuery(t.url, "sid", sid)
+}
+
+// HandshakeError is returned when the server rejects the Engine.IO handshake,
+// for example because an AllowRequest hook returned an error.
+type HandshakeError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+
+	// Code is the Engine.IO error code, if the server sent one.
+	Code int
+
+	// Message is the error message sent by the server.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *HandshakeError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("handshake failed with status %d", e.StatusCode)
+	}
+	return "handshake failed: " + e.Message
+}
+
+// responseError reads the Engine.IO error from a rejected request
+func responseError(resp *http.Response) error {
+	err := &HandshakeError{StatusCode: resp.StatusCode}
+
+	var body struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if data, readErr := io.ReadAll(io.LimitReader(resp.Body, 4096)); readErr == nil {
+		if json.Unmarshal(data, &body) == nil {
+			err.Code = body.Code
+			err.Message = body.Message
+		}
+	}
+
+	return err
+}
+
+func withQuery(rawURL, key, value string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,123 @@
+package gosocketio
+
+import "context"
+
+// RemoteSocket describes a socket returned by BroadcastOperator.FetchSockets.
+// The socket may be connected to this server or to another one in the cluster.
+//
+// Its fields are a snapshot taken when the sockets were fetched. For sockets
+// connected to another server, Handshake.TLS is nil and Data holds the values
+// as decoded from JSON.
+type RemoteSocket struct {
+	// ID is the socket ID.
+	ID string
+
+	// Handshake holds the details of the connection that opened the socket.
+	Handshake *Handshake
+
+	// Rooms are the rooms the socket has joined, including its own room.
+	Rooms []string
+
+	// Data is the data stored on the socket with Set.
+	Data map[string]interface{}
+
+	namespace *Namespace
+}
+
+// newRemoteSocket returns a snapshot of a socket connected to this server
+func newRemoteSocket(socket *Socket) *RemoteSocket {
+	return &RemoteSocket{
+		ID:        socket.id,
+		Handshake: socket.handshake,
+		Rooms:     socket.Rooms(),
+		Data:      socket.snapshotData(),
+		namespace: socket.namespace,
+	}
+}
+
+// Emit sends an event to the socket, wherever it is connected.
+func (s *RemoteSocket) Emit(event string, data ...interface{}) error {
+	return s.namespace.To(s.ID).Emit(event, data...)
+}
+
+// Join adds the socket to the rooms, wherever it is connected.
+func (s *RemoteSocket) Join(rooms ...string) error {
+	return s.namespace.To(s.ID).SocketsJoin(rooms...)
+}
+
+// Leave removes the socket from the rooms, wherever it is connected.
+func (s *RemoteSocket) Leave(rooms ...string) error {
+	return s.namespace.To(s.ID).SocketsLeave(rooms...)
+}
+
+// Disconnect disconnects the socket from its namespace, or closes its
+// underlying connection if close is true.
+func (s *RemoteSocket) Disconnect(close bool) error {
+	return s.namespace.To(s.ID).DisconnectSockets(close)
+}
+
+// ClusterAdapter is an optional interface for adapters that can act on the
+// sockets matching a broadcast target on every server of the cluster.
+//
+// It is used by BroadcastOperator.FetchSockets, SocketsJoin, SocketsLeave and
+// DisconnectSockets. With adapters that do not implement it, those only reach
+// the sockets connected to this server. MemoryAdapter implements it.
+//
+// The rooms and except parameters select sockets like they do for Broadcast.
+type ClusterAdapter interface {
+	Adapter
+
+	// FetchSockets returns the matching sockets of every server. It returns
+	// an error if some servers did not answer before ctx is done.
+	FetchSockets(ctx context.Context, rooms []string, except []string) ([]*RemoteSocket, error)
+
+	// AddSockets makes the matching sockets of every server join the rooms
+	// in join.
+	AddSockets(rooms []string, except []string, join []string) error
+
+	// DelSockets makes the matching sockets of every server leave the rooms
+	// in leave.
+	DelSockets(rooms []string, except []string, leave []string) error
+
+	// DisconnectSockets disconnects the matching sockets of every server
+	// from the namespace, or closes their connection if close is true.
+	DisconnectSockets(rooms []string, except []string, close bool) error
+}
+
+// joinSockets makes every socket join the rooms
+func joinSockets(sockets []*Socket, rooms []string) {
+	for _, socket := range sockets {
+		for _, room := range rooms {
+			socket.Join(room)
+		}
+	}
+}
+
+// leaveSockets makes every socket leave the rooms
+func leaveSockets(sockets []*Socket, rooms []string) {
+	for _, socket := range sockets {
+		for _, room := range rooms {
+			socket.Leave(room)
+		}
+	}
+}
+
+// disconnectSockets disconnects every socket from its namespace, or closes
+// its connection if close is true
+func disconnectSockets(sockets []*Socket, close bool) {
+	for _, socket := range sockets {
+		if close {
+			socket.Close()
+		} else {
+			socket.Disconnect()
+		}
+	}
+}
+
+func remoteSockets(sockets []*Socket) []*RemoteSocket {
+	result := make([]*RemoteSocket, 0, len(sockets))
+	for _, socket := range sockets {
+		result = append(result, newRemoteSocket(socket))
+	}
+	return result
+}
@@ -0,0 +1,195 @@
+package gosocketio
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DispatchMode selects how event handlers are run.
+type DispatchMode int
+
+const (
+	// DispatchConcurrent runs every handler in its own goroutine. Events
+	// from the same client may be processed in any order. This is the default.
+	DispatchConcurrent DispatchMode = iota
+
+	// DispatchOrdered runs the handlers of each socket one at a time, in the
+	// order the events were received.
+	DispatchOrdered
+
+	// DispatchOrderedPerEvent runs the handlers of each socket one at a
+	// time per event name, so that events with the same name are processed
+	// in order while different events are processed concurrently.
+	DispatchOrderedPerEvent
+
+	// DispatchPool runs handlers on a fixed number of goroutines shared by
+	// all sockets. Events are processed in any order.
+	DispatchPool
+)
+
+// dispatcher runs event handlers according to a DispatchMode.
+//
+// Ordered and pooled dispatchers have bounded queues: once full, dispatch
+// blocks, which stops reading from the connection until handlers catch up.
+type dispatcher interface {
+	// dispatch runs fn, queued behind the tasks with the same key if the
+	// dispatcher preserves ordering
+	dispatch(key string, fn func())
+}
+
+func newDispatcher(server *Server) dispatcher {
+	if server == nil {
+		return concurrentDispatcher{}
+	}
+
+	size := server.config.DispatchQueueSize
+	switch server.config.DispatchMode {
+	case DispatchOrdered:
+		return newSerialQueue(size)
+	case DispatchOrderedPerEvent:
+		return &keyedDispatcher{size: size, queues: make(map[string]*keyedQueue)}
+	case DispatchPool:
+		return server.pool
+	default:
+		return concurrentDispatcher{}
+	}
+}
+
+// concurrentDispatcher runs every task in its own goroutine
+type concurrentDispatcher struct{}
+
+func (concurrentDispatcher) dispatch(key string, fn func()) {
+	go fn()
+}
+
+// serialQueue runs tasks one at a time in the order they were dispatched. Its
+// goroutine only runs while tasks are pending.
+type serialQueue struct {
+	tasks   chan func()
+	running bool
+	mu      sync.Mutex
+}
+
+func newSerialQueue(size int) *serialQueue {
+	return &serialQueue{tasks: make(chan func(), size)}
+}
+
+func (q *serialQueue) dispatch(key string, fn func()) {
+	q.tasks <- fn
+
+	q.mu.Lock()
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+	q.mu.Unlock()
+}
+
+func (q *serialQueue) run() {
+	for {
+		select {
+		case fn := <-q.tasks:
+			fn()
+		default:
+			q.mu.Lock()
+			if len(q.tasks) == 0 {
+				q.running = false
+				q.mu.Unlock()
+				return
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+// keyedDispatcher keeps a serial queue per key. Keys are event names chosen
+// by the client, so a queue is removed as soon as it has no pending tasks.
+type keyedDispatcher struct {
+	size   int
+	queues map[string]*keyedQueue
+	mu     sync.Mutex
+}
+
+type keyedQueue struct {
+	*serialQueue
+
+	// pending is the number of tasks dispatched and not yet run. It is
+	// guarded by the mutex of the dispatcher.
+	pending int
+}
+
+func (d *keyedDispatcher) dispatch(key string, fn func()) {
+	d.mu.Lock()
+	queue, ok := d.queues[key]
+	if !ok {
+		queue = &keyedQueue{serialQueue: newSerialQueue(d.size)}
+		d.queues[key] = queue
+	}
+	queue.pending++
+	d.mu.Unlock()
+
+	queue.dispatch(key, func() {
+		defer d.done(key, queue)
+		fn()
+	})
+}
+
+// done removes the queue of the key once it ran its last pending task. A
+// task dispatched afterwards gets a new queue, which cannot run before the
+// task of this one completed.
+func (d *keyedDispatcher) done(key string, queue *keyedQueue) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	queue.pending--
+	if queue.pending == 0 {
+		delete(d.queues, key)
+	}
+}
+
+// workerPool runs tasks on a fixed number of goroutines, until it is closed
+type workerPool struct {
+	tasks     chan func()
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWorkerPool(workers, size int) *workerPool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	p := &workerPool{
+		tasks: make(chan func(), size),
+		done:  make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go p.run()
+	}
+	return p
+}
+
+// dispatch queues the task. Once the pool is closed, tasks are dropped.
+func (p *workerPool) dispatch(key string, fn func()) {
+	select {
+	case p.tasks <- fn:
+	case <-p.done:
+	}
+}
+
+func (p *workerPool) run() {
+	for {
+		select {
+		case fn := <-p.tasks:
+			fn()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// close stops the workers once they finish their current task. Queued tasks
+// are not run.
+func (p *workerPool) close() {
+	p.closeOnce.Do(func() { close(p.done) })
+}
@@ -0,0 +1,80 @@
+package gosocketio
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedDispatcherRemovesIdleQueues(t *testing.T) {
+	d := &keyedDispatcher{size: 10, queues: make(map[string]*keyedQueue)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		d.dispatch(fmt.Sprintf("event-%d", i), wg.Done)
+	}
+	wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.queues) != 0 {
+		t.Fatalf("%d queues left after all tasks ran", len(d.queues))
+	}
+}
+
+func TestKeyedDispatcherKeepsOrder(t *testing.T) {
+	d := &keyedDispatcher{size: 10, queues: make(map[string]*keyedQueue)}
+
+	var mu sync.Mutex
+	var got []int
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		i := i
+		wg.Add(1)
+		d.dispatch("event", func() {
+			defer wg.Done()
+			mu.Lock()
+			got = append(got, i)
+			mu.Unlock()
+		})
+		// Let the queue drain now and then, so that it is removed and
+		// created again
+		if i%10 == 0 {
+			wg.Wait()
+		}
+	}
+	wg.Wait()
+
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("tasks ran in order %v", got)
+		}
+	}
+}
+
+func TestWorkerPoolClose(t *testing.T) {
+	p := newWorkerPool(2, 1)
+
+	ran := make(chan struct{})
+	p.dispatch("", func() { close(ran) })
+	<-ran
+
+	p.close()
+	p.close()
+
+	// Dispatching to a closed pool drops the tasks instead of blocking
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			p.dispatch("", func() {})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch blocked on a closed pool")
+	}
+}
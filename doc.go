@@ -1,19 +1,24 @@
 // Package gosocketio provides a Socket.IO v4 server implementation in Go.
 //
-// This library implements the Socket.IO v4 protocol with WebSocket transport only,
+// This library implements the Socket.IO v4 protocol over WebSocket and HTTP long-polling,
 // optimized for high-concurrency applications such as chat aggregation platforms.
 // It is designed to handle tens of thousands of concurrent connections efficiently.
 //
 // # Features
 //
 //   - Socket.IO v4 protocol support
-//   - WebSocket transport only (Engine.IO v4)
+//   - WebSocket and HTTP long-polling transports (Engine.IO v4)
 //   - Namespaces for logical separation
 //   - Rooms for grouping connections
 //   - Event acknowledgments
 //   - Binary data support
 //   - Efficient broadcasting
+//   - Multi-server broadcasting through Redis
 //   - Compatible with official Socket.IO clients
+//   - Optional compatibility with socket.io-client 2.x (Engine.IO v3)
+//   - Bounded outgoing queues with slow-client policies
+//   - Go client in the client package
+//   - In-memory test harness in the siotest package
 //
 // # Quick Start
 //
@@ -38,7 +43,10 @@
 // # Namespaces
 //
 // Namespaces provide logical separation of concerns. Each namespace has its own
-// event handlers and rooms.
+// event handlers and rooms. A single client connection can join several
+// namespaces at once; connecting to a namespace that was never created with
+// Of, and that no parent namespace matches, is rejected with a CONNECT_ERROR
+// packet.
 //
 //	// Default namespace "/"
 //	server.OnConnect(func(socket *gosocketio.Socket) {
@@ -51,6 +59,46 @@
 //	    // Handle admin connection
 //	})
 //
+//	// Child namespaces created on demand, such as "/tenant-42"
+//	tenants := server.OfRegexp(regexp.MustCompile(`^/tenant-\d+$`))
+//	tenants.OnConnect(func(socket *gosocketio.Socket) {
+//	    // Handle tenant connection
+//	})
+//
+// # Middleware
+//
+// Middlewares run before a socket is admitted to a namespace and can reject
+// the connection, for example after checking the client's auth payload:
+//
+//	server.Of("/").Use(func(socket *gosocketio.Socket, next func(error)) {
+//	    if socket.Auth()["token"] != expectedToken {
+//	        next(errors.New("unauthorized"))
+//	        return
+//	    }
+//	    next(nil)
+//	})
+//
+// Socket middlewares run for every event a socket receives, before its handlers.
+// Rejected events are reported to the socket's "error" handlers, and to the
+// client as an error acknowledgment or "error" event:
+//
+//	socket.Use(func(event string, args []interface{}, next func(error)) {
+//	    if !limiter.Allow() {
+//	        next(errors.New("rate limited"))
+//	        return
+//	    }
+//	    next(nil)
+//	})
+//
+// Catch-all listeners observe every event received or emitted by a socket:
+//
+//	socket.OnAny(func(event string, args ...interface{}) {
+//	    log.Printf("Received %s", event)
+//	})
+//	socket.OnAnyOutgoing(func(event string, args ...interface{}) {
+//	    log.Printf("Sending %s", event)
+//	})
+//
 // # Rooms
 //
 // Rooms allow you to group sockets for targeted broadcasting.
@@ -67,6 +115,13 @@
 //	    log.Printf("Client answered: %v", response)
 //	}, "What's your name?")
 //
+// Wait for an acknowledgment with a deadline:
+//
+//	response, err := socket.Timeout(5*time.Second).EmitWithAckContext(ctx, "question", "What's your name?")
+//	if err != nil {
+//	    // context.DeadlineExceeded, ErrSocketDisconnected or ctx.Err()
+//	}
+//
 // Handle acknowledgment requests from clients:
 //
 //	socket.On("ping", func(data ...interface{}) {
@@ -78,6 +133,28 @@
 //	    }
 //	})
 //
+// # Binary Data
+//
+// []byte values anywhere in the event arguments (including inside
+// []interface{} and map[string]interface{} values) are sent as binary
+// attachments instead of being base64-encoded. Binary data received from
+// clients is delivered to handlers as []byte.
+//
+//	socket.Emit("thumbnail", map[string]interface{}{"id": 42, "image": png})
+//
+// # Typed Handlers
+//
+// On and OnWithAck decode the event payload straight into a struct, and
+// reject invalid payloads:
+//
+//	gosocketio.OnWithAck(socket, "join", func(s *gosocketio.Socket, req JoinRequest, ack func(JoinResponse)) error {
+//	    s.Join(req.Room)
+//	    ack(JoinResponse{Room: req.Room})
+//	    return nil
+//	})
+//
+// Payloads implementing Validator are validated after decoding.
+//
 // # Broadcasting
 //
 // Broadcast to all clients or specific rooms:
@@ -91,6 +168,15 @@
 //	// Exclude specific sockets
 //	server.To("room1").Except(socket.ID()).Emit("news", "Hello others!")
 //
+//	// Collect acknowledgments from every recipient
+//	result, err := server.Timeout(5*time.Second).EmitWithAckContext(ctx, "still_there")
+//
+// Act on every socket matching a target, on every server with a cluster adapter:
+//
+//	server.To("room1").SocketsJoin("room2")
+//	server.To("room1").DisconnectSockets(true)
+//	sockets, err := server.To("room1").FetchSockets(ctx)
+//
 // # Configuration
 //
 // Customize server behavior with Config:
@@ -102,8 +188,118 @@
 //	}
 //	server := gosocketio.NewServer(config)
 //
+// Browsers on other origins are rejected by default, over both WebSocket and
+// HTTP long-polling. Allow them, with cookies if needed (CORS):
+//
+//	config := &gosocketio.Config{
+//	    AllowedOrigins:   []string{"https://app.example.com", "https://*.example.com"},
+//	    AllowCredentials: true,
+//	}
+//
+// Use the MessagePack parser from the msgpackparser package for clients using
+// socket.io-msgpack-parser:
+//
+//	config := &gosocketio.Config{
+//	    Parser: msgpackparser.Parser{},
+//	}
+//
+// Accept socket.io-client 2.x clients, which speak Socket.IO v2 over
+// Engine.IO v3, alongside v4 clients:
+//
+//	config := &gosocketio.Config{
+//	    AllowEIO3: true,
+//	}
+//
+// Bound the packets queued for slow clients and choose what happens when a
+// queue is full:
+//
+//	config := &gosocketio.Config{
+//	    MaxQueuedPackets: 1000,
+//	    MaxQueuedBytes:   1 << 20,
+//	    OverflowPolicy:   engineio.OverflowDisconnect,
+//	    OnOverflow: func(session *engineio.Session, packets []*engineio.Packet) {
+//	        overflows.Inc()
+//	    },
+//	}
+//
+// # Connection State Recovery
+//
+// Clients that lose their connection briefly (ping timeout, network errors)
+// can get back their socket ID, rooms, data and the broadcasts they missed:
+//
+//	config := &gosocketio.Config{
+//	    ConnectionStateRecovery: &gosocketio.RecoveryConfig{
+//	        MaxDisconnectionDuration: 120000, // 2 minutes
+//	    },
+//	}
+//
+// Socket.Recovered reports whether a socket was restored.
+//
+// # Multiple Servers
+//
+// By default rooms and broadcasts are local to one process. To run several
+// servers behind a load balancer, plug in an adapter shared by all of them,
+// such as the Redis adapter from the redisadapter package:
+//
+//	config := &gosocketio.Config{
+//	    AdapterFactory: redisadapter.Factory(redisClient, nil),
+//	}
+//
+// Use Local on a BroadcastOperator to reach the sockets of the current
+// server only, as in server.To("room").Local().Emit(...).
+// HTTP long-polling clients need sticky sessions so that all their requests
+// reach the same server.
+//
+// Servers can also send events to each other:
+//
+//	ns.OnServerSideEvent("invalidate", func(args ...interface{}) {
+//	    cache.Delete(args[0].(string))
+//	})
+//
+//	ns.ServerSideEmit("invalidate", "user:42")
+//
+// # Error Handling
+//
+// Panics in handlers, acknowledgment callbacks and middlewares are recovered,
+// so one faulty handler cannot crash the server, and reported to OnError:
+//
+//	server.OnError(func(err error, socket *gosocketio.Socket, event string) {
+//	    log.Printf("Handler for %q failed: %v", event, err)
+//	})
+//
+// Set Config.AckOnPanic to answer pending acknowledgments with an error when
+// their handler panics.
+//
+// # Go Client
+//
+// The client package connects to a Socket.IO server from Go, for services,
+// command-line tools and end-to-end tests:
+//
+//	socket, err := client.Connect(ctx, "http://localhost:3000/chat", nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	response, err := socket.EmitWithAckContext(ctx, "join", "lobby")
+//
+// # Testing
+//
+// The siotest package runs a server over an in-memory network, with clients
+// that record the events they receive and a fake clock for ping timers:
+//
+//	server := siotest.NewServer(t, nil)
+//	alice := server.Connect(t, "/")
+//	alice.Emit("join", "lobby")
+//	alice.ExpectEvent(t, "joined", time.Second)
+//	server.Clock.Advance(25 * time.Second)
+//
 // # Thread Safety
 //
-// All operations are goroutine-safe. Event handlers are called in separate
-// goroutines, allowing concurrent processing of events.
+// All operations are goroutine-safe. By default event handlers are called in
+// separate goroutines, allowing concurrent processing of events. Set
+// Config.DispatchMode to process the events of each socket in order, or to
+// run handlers on a bounded pool of goroutines:
+//
+//	config := &gosocketio.Config{
+//	    DispatchMode: gosocketio.DispatchOrdered,
+//	}
 package gosocketio
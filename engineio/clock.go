@@ -0,0 +1,41 @@
+package engineio
+
+import "time"
+
+// Clock schedules the ping timers of sessions. The default clock uses the
+// time package; tests can replace it to control time, see the siotest package.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// AfterFunc calls f once d has elapsed.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer created by a Clock.
+type Timer interface {
+	// Stop prevents the timer from firing. It returns false if the timer
+	// already fired or was stopped.
+	Stop() bool
+}
+
+// RealClock is the Clock backed by the time package, used by default.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// clock returns the clock configured for the server
+func (s *Server) clock() Clock {
+	if s.config.Clock == nil {
+		return RealClock
+	}
+	return s.config.Clock
+}
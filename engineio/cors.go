@@ -0,0 +1,143 @@
+package engineio
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// checkOrigin reports whether the request origin is allowed by the configured
+// policy. Requests without an Origin header (non-browser clients) and
+// same-origin requests are always allowed; cross-origin requests only when
+// AllowedOrigins or CheckOrigin allows them.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	allowed, _ := s.matchRequestOrigin(r)
+	return allowed
+}
+
+// matchRequestOrigin reports whether the request origin is allowed, and
+// whether it was matched explicitly, by an exact or wildcard subdomain entry
+// of AllowedOrigins or by CheckOrigin. Only explicitly matched origins may
+// send credentials.
+func (s *Server) matchRequestOrigin(r *http.Request) (allowed, explicit bool) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true, false
+	}
+
+	anyOrigin := false
+	for _, pattern := range s.config.AllowedOrigins {
+		if pattern == "*" {
+			anyOrigin = true
+		} else if matchOrigin(pattern, origin) {
+			return true, true
+		}
+	}
+
+	if s.config.CheckOrigin != nil && s.config.CheckOrigin(r) {
+		return true, true
+	}
+
+	return anyOrigin || sameOrigin(r, origin), false
+}
+
+// sameOrigin reports whether the origin is the host the request was sent to
+func sameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// matchOrigin matches an origin against an exact origin or a wildcard
+// subdomain pattern such as "https://*.example.com". The scheme, host and
+// port are compared separately: a pattern without a port only matches the
+// default port of its scheme, and a "*" port matches any port.
+func matchOrigin(pattern, origin string) bool {
+	// url.Parse rejects "*" ports, so the pattern is split by hand
+	scheme, patternHost, ok := strings.Cut(strings.ToLower(pattern), "://")
+	if !ok {
+		return false
+	}
+	patternHost, patternPort := splitHostPort(patternHost)
+
+	o, err := url.Parse(strings.ToLower(origin))
+	if err != nil || o.Scheme != scheme {
+		return false
+	}
+	if patternPort != "*" && defaultPort(scheme, patternPort) != defaultPort(o.Scheme, o.Port()) {
+		return false
+	}
+
+	host := o.Hostname()
+	if suffix, ok := strings.CutPrefix(patternHost, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == patternHost
+}
+
+// splitHostPort splits a host into its name and its port, if any
+func splitHostPort(host string) (string, string) {
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		return host[:i], host[i+1:]
+	}
+	return host, ""
+}
+
+// defaultPort returns port, or the default port of the scheme if it is empty
+func defaultPort(scheme, port string) string {
+	if port != "" {
+		return port
+	}
+	switch scheme {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	}
+	return ""
+}
+
+// setCORSHeaders adds the CORS response headers for an allowed origin.
+// Credentials are only allowed for explicitly matched origins, never for
+// origins allowed by "*" alone.
+func (s *Server) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+
+	allowed, explicit := s.matchRequestOrigin(r)
+	if !allowed {
+		return
+	}
+
+	header := w.Header()
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Add("Vary", "Origin")
+
+	if s.config.AllowCredentials && explicit {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// servePreflight answers a CORS preflight request
+func (s *Server) servePreflight(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+
+	if len(s.config.AllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(s.config.AllowedHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if s.config.CORSMaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(s.config.CORSMaxAge))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
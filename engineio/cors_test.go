@@ -0,0 +1,79 @@
+package engineio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		pattern, origin string
+		want            bool
+	}{
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "https://EXAMPLE.com", true},
+		{"https://example.com", "https://example.com:443", true},
+		{"https://example.com", "http://example.com", false},
+		{"https://example.com", "https://example.com:8443", false},
+		{"https://example.com:8443", "https://example.com:8443", true},
+		{"https://*.example.com", "https://app.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://evilexample.com", false},
+		{"https://*.example.com", "https://app.example.com.evil.com", false},
+		{"https://*.example.com", "https://app.example.com:8443", false},
+		{"https://*.example.com:8443", "https://app.example.com:8443", true},
+		{"https://*.example.com:*", "https://app.example.com:8443", true},
+		{"https://*.example.com:*", "https://app.example.com", true},
+		{"http://localhost:3000", "http://localhost:3000", true},
+		{"http://localhost:3000", "http://localhost:3001", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchOrigin(tt.pattern, tt.origin); got != tt.want {
+			t.Errorf("matchOrigin(%q, %q) = %v, want %v", tt.pattern, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSHeaders(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          *Config
+		origin          string
+		wantAllowed     bool
+		wantCredentials bool
+	}{
+		{"no origin", &Config{AllowCredentials: true}, "", true, false},
+		{"same origin", &Config{AllowCredentials: true}, "http://example.com", true, false},
+		{"cross origin denied by default", &Config{AllowCredentials: true}, "https://evil.com", false, false},
+		{"any origin without credentials", &Config{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "https://evil.com", true, false},
+		{"listed origin with credentials", &Config{AllowedOrigins: []string{"https://app.com"}, AllowCredentials: true}, "https://app.com", true, true},
+		{"unlisted origin", &Config{AllowedOrigins: []string{"https://app.com"}, AllowCredentials: true}, "https://evil.com", false, false},
+		{"CheckOrigin", &Config{CheckOrigin: func(*http.Request) bool { return true }, AllowCredentials: true}, "https://app.com", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(tt.config)
+
+			r := httptest.NewRequest("GET", "http://example.com/engine.io/", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			s.setCORSHeaders(w, r)
+
+			if got := s.checkOrigin(r); got != tt.wantAllowed {
+				t.Errorf("checkOrigin = %v, want %v", got, tt.wantAllowed)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); tt.origin != "" && (got != "") != tt.wantAllowed {
+				t.Errorf("Access-Control-Allow-Origin = %q, want allowed %v", got, tt.wantAllowed)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %v, want %v", got, tt.wantCredentials)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package engineio
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Engine.IO protocol revisions
+const (
+	ProtocolV3 = 3
+	ProtocolV4 = 4
+)
+
+// binaryPayloadSeparator ends the length of a packet in a v3 binary payload
+const binaryPayloadSeparator = 0xff
+
+// encodePacketV3 encodes a packet for a v3 long-polling payload, where binary
+// packets are base64-encoded with a "b" prefix followed by their type
+func encodePacketV3(p *Packet) []byte {
+	if !p.Binary {
+		return p.Encode()
+	}
+
+	result := make([]byte, 2+base64.StdEncoding.EncodedLen(len(p.Data)))
+	result[0] = 'b'
+	result[1] = byte('0' + p.Type)
+	base64.StdEncoding.Encode(result[2:], p.Data)
+	return result
+}
+
+// decodePacketV3 decodes a packet of a v3 long-polling text payload
+func decodePacketV3(data []byte) (*Packet, error) {
+	if len(data) == 0 || data[0] != 'b' {
+		return DecodePacket(data)
+	}
+
+	if len(data) < 2 || data[1] != byte('0'+PacketTypeMessage) {
+		return nil, fmt.Errorf("invalid binary packet")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(data[2:]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid binary packet: %w", err)
+	}
+	return &Packet{Type: PacketTypeMessage, Data: decoded, Binary: true}, nil
+}
+
+// encodePayloadV3 encodes packets into a v3 long-polling text payload, in
+// which every packet is prefixed with its length and a colon. Lengths count
+// UTF-16 code units, as JavaScript strings do.
+func encodePayloadV3(packets []*Packet) []byte {
+	var result []byte
+	for _, packet := range packets {
+		result = appendFrameV3(result, encodePacketV3(packet))
+	}
+	return result
+}
+
+// appendFrameV3 appends an encoded packet to a v3 text payload
+func appendFrameV3(payload, encoded []byte) []byte {
+	payload = strconv.AppendInt(payload, int64(utf16Len(encoded)), 10)
+	payload = append(payload, ':')
+	return append(payload, encoded...)
+}
+
+// decodePayloadV3 decodes a v3 long-polling text payload into packets
+func decodePayloadV3(data []byte) ([]*Packet, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+
+	var packets []*Packet
+	for len(data) > 0 {
+		colon := 0
+		for colon < len(data) && data[colon] != ':' {
+			colon++
+		}
+		length, err := strconv.Atoi(string(data[:colon]))
+		if err != nil || colon == len(data) || length <= 0 {
+			return nil, fmt.Errorf("invalid payload length")
+		}
+		data = data[colon+1:]
+
+		// Convert the length from UTF-16 code units to bytes
+		end := 0
+		for units := 0; units < length; {
+			if end == len(data) {
+				return nil, fmt.Errorf("truncated payload")
+			}
+			r, size := utf8.DecodeRune(data[end:])
+			units += utf16Units(r)
+			end += size
+		}
+
+		packet, err := decodePacketV3(data[:end])
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+		data = data[end:]
+	}
+	return packets, nil
+}
+
+// decodeBinaryPayloadV3 decodes a v3 long-polling binary payload, sent by
+// clients supporting binary as application/octet-stream. Every packet is
+// prefixed with 0 for text or 1 for binary, its length as one byte per
+// decimal digit, and 0xff. Binary packets start with their type as a byte.
+func decodeBinaryPayloadV3(data []byte) ([]*Packet, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+
+	var packets []*Packet
+	for len(data) > 0 {
+		binary := data[0] == 1
+		if data[0] > 1 {
+			return nil, fmt.Errorf("invalid payload packet kind: %d", data[0])
+		}
+
+		length, i := 0, 1
+		for ; i < len(data) && data[i] != binaryPayloadSeparator; i++ {
+			if data[i] > 9 || i > 10 {
+				return nil, fmt.Errorf("invalid payload length")
+			}
+			length = length*10 + int(data[i])
+		}
+		if i == 1 || i == len(data) || length == 0 || len(data)-i-1 < length {
+			return nil, fmt.Errorf("invalid payload length")
+		}
+		chunk := data[i+1 : i+1+length]
+		data = data[i+1+length:]
+
+		if !binary {
+			packet, err := DecodePacket(chunk)
+			if err != nil {
+				return nil, err
+			}
+			packets = append(packets, packet)
+			continue
+		}
+
+		if chunk[0] != byte(PacketTypeMessage) {
+			return nil, fmt.Errorf("invalid binary packet type: %d", chunk[0])
+		}
+		packets = append(packets, &Packet{Type: PacketTypeMessage, Data: chunk[1:], Binary: true})
+	}
+	return packets, nil
+}
+
+// utf16Len returns the length of UTF-8 text in UTF-16 code units
+func utf16Len(data []byte) int {
+	n := 0
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		n += utf16Units(r)
+		data = data[size:]
+	}
+	return n
+}
+
+func utf16Units(r rune) int {
+	if r > 0xffff {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,153 @@
+package engineio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUTF16Len(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"4hello", 6},
+		{"4héllo", 6},
+		{"4€", 2},
+		{"4😀", 3},
+		{"4a😀b𝄞", 7},
+	}
+
+	for _, tt := range tests {
+		if got := utf16Len([]byte(tt.text)); got != tt.want {
+			t.Errorf("utf16Len(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEncodePayloadV3(t *testing.T) {
+	packets := []*Packet{
+		message("héllo"),
+		message("😀"),
+		{Type: PacketTypeMessage, Data: []byte{1, 2}, Binary: true},
+		{Type: PacketTypePong, Data: []byte("probe")},
+	}
+
+	want := "6:4héllo3:4😀6:b4AQI=6:3probe"
+	if got := string(encodePayloadV3(packets)); got != want {
+		t.Fatalf("encodePayloadV3 = %q, want %q", got, want)
+	}
+}
+
+func TestDecodePayloadV3(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []*Packet
+	}{
+		{"single", "6:4hello", []*Packet{message("hello")}},
+		{"multibyte", "6:4héllo", []*Packet{message("héllo")}},
+		{"surrogate pairs", "3:4😀5:4a𝄞b", []*Packet{message("😀"), message("a𝄞b")}},
+		{"binary", "6:b4AQI=2:4a", []*Packet{
+			{Type: PacketTypeMessage, Data: []byte{1, 2}, Binary: true},
+			message("a"),
+		}},
+		{"control", "1:26:2probe", []*Packet{{Type: PacketTypePing}, {Type: PacketTypePing, Data: []byte("probe")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packets, err := decodePayloadV3([]byte(tt.payload))
+			if err != nil {
+				t.Fatalf("decodePayloadV3: %v", err)
+			}
+			checkPackets(t, packets, tt.want)
+		})
+	}
+}
+
+func TestDecodePayloadV3Malformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"empty", ""},
+		{"no colon", "6"},
+		{"no length", ":4hello"},
+		{"not a number", "x:4hello"},
+		{"zero length", "0:"},
+		{"negative length", "-1:4"},
+		{"truncated", "7:4hello"},
+		// Counted in bytes instead of UTF-16 code units
+		{"byte length", "5:4😀"},
+		{"trailing garbage", "6:4hello1"},
+		{"invalid base64", "4:b4!!"},
+		{"binary control packet", "6:b2AQI="},
+		{"invalid packet type", "2:9a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if packets, err := decodePayloadV3([]byte(tt.payload)); err == nil {
+				t.Fatalf("decodePayloadV3(%q) = %v, want an error", tt.payload, packets)
+			}
+		})
+	}
+}
+
+func TestDecodeBinaryPayloadV3(t *testing.T) {
+	payload := []byte{0, 6, 0xff}
+	payload = append(payload, "4hello"...)
+	payload = append(payload, 1, 3, 0xff, 4, 1, 2)
+	payload = append(payload, 0, 1, 2, 0xff)
+	payload = append(payload, "4héllo 😀"...)
+
+	packets, err := decodeBinaryPayloadV3(payload)
+	if err != nil {
+		t.Fatalf("decodeBinaryPayloadV3: %v", err)
+	}
+	checkPackets(t, packets, []*Packet{
+		message("hello"),
+		{Type: PacketTypeMessage, Data: []byte{1, 2}, Binary: true},
+		message("héllo 😀"),
+	})
+}
+
+func TestDecodeBinaryPayloadV3Malformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"empty", nil},
+		{"invalid kind", []byte{2, 1, 0xff, '2'}},
+		{"no length", []byte{0, 0xff, '2'}},
+		{"zero length", []byte{0, 0, 0xff}},
+		{"invalid digit", []byte{0, 10, 0xff, '2'}},
+		{"too many digits", []byte{0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0xff, '2'}},
+		{"no separator", []byte{0, 1, '2'}},
+		{"truncated", []byte{0, 6, 0xff, '4', 'a'}},
+		{"binary control packet", []byte{1, 2, 0xff, 2, 1}},
+		{"invalid text packet", []byte{0, 1, 0xff, '9'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if packets, err := decodeBinaryPayloadV3(tt.payload); err == nil {
+				t.Fatalf("decodeBinaryPayloadV3(%v) = %v, want an error", tt.payload, packets)
+			}
+		})
+	}
+}
+
+func checkPackets(t *testing.T, got, want []*Packet) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d packets, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || got[i].Binary != want[i].Binary || !bytes.Equal(got[i].Data, want[i].Data) {
+			t.Errorf("packet %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
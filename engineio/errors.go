@@ -0,0 +1,61 @@
+package engineio
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorCode is an Engine.IO error code sent to clients whose HTTP request
+// is rejected
+type ErrorCode int
+
+const (
+	ErrorTransportUnknown ErrorCode = iota
+	ErrorUnknownSID
+	ErrorBadHandshakeMethod
+	ErrorBadRequest
+	ErrorForbidden
+	ErrorUnsupportedProtocolVersion
+)
+
+// String returns the standard message for the error code
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrorTransportUnknown:
+		return "Transport unknown"
+	case ErrorUnknownSID:
+		return "Session ID unknown"
+	case ErrorBadHandshakeMethod:
+		return "Bad handshake method"
+	case ErrorBadRequest:
+		return "Bad request"
+	case ErrorForbidden:
+		return "Forbidden"
+	case ErrorUnsupportedProtocolVersion:
+		return "Unsupported protocol version"
+	default:
+		return "Unknown error"
+	}
+}
+
+// writeError writes the JSON error body for the code. If message is empty,
+// the standard message for the code is used.
+func writeError(w http.ResponseWriter, code ErrorCode, message string) {
+	if message == "" {
+		message = code.String()
+	}
+
+	status := http.StatusBadRequest
+	if code == ErrorForbidden {
+		status = http.StatusForbidden
+	}
+
+	body, _ := json.Marshal(struct {
+		Code    ErrorCode `json:"code"`
+		Message string    `json:"message"`
+	}{code, message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
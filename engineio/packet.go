@@ -1,6 +1,8 @@
 package engineio
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -23,10 +25,22 @@ const (
 type Packet struct {
 	Type PacketType
 	Data []byte
+
+	// Binary marks a message packet carrying raw binary data. It is sent as
+	// a binary WebSocket frame, or base64-encoded with a "b" prefix when
+	// sent over HTTP long-polling.
+	Binary bool
 }
 
 // Encode encodes the packet to bytes
 func (p *Packet) Encode() []byte {
+	if p.Binary {
+		result := make([]byte, 1+base64.StdEncoding.EncodedLen(len(p.Data)))
+		result[0] = 'b'
+		base64.StdEncoding.Encode(result[1:], p.Data)
+		return result
+	}
+
 	result := make([]byte, 0, len(p.Data)+1)
 	result = append(result, byte('0'+p.Type))
 	result = append(result, p.Data...)
@@ -40,6 +54,14 @@ func DecodePacket(data []byte) (*Packet, error) {
 	}
 
 	typeChar := data[0]
+	if typeChar == 'b' {
+		decoded, err := base64.StdEncoding.DecodeString(string(data[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid binary packet: %w", err)
+		}
+		return &Packet{Type: PacketTypeMessage, Data: decoded, Binary: true}, nil
+	}
+
 	if typeChar < '0' || typeChar > '6' {
 		return nil, fmt.Errorf("invalid packet type: %c", typeChar)
 	}
@@ -74,7 +96,12 @@ func EncodeHandshake(sid string, pingInterval, pingTimeout, maxPayload int) ([]b
 		MaxPayload:   maxPayload,
 	}
 
-	jsonData, err := json.Marshal(data)
+	return data.Encode()
+}
+
+// Encode creates an open packet carrying the handshake data
+func (h *HandshakeData) Encode() ([]byte, error) {
+	jsonData, err := json.Marshal(h)
 	if err != nil {
 		return nil, err
 	}
@@ -108,3 +135,40 @@ func (pt PacketType) String() string {
 		return "unknown(" + strconv.Itoa(int(pt)) + ")"
 	}
 }
+
+// payloadSeparator delimits packets inside an HTTP long-polling payload
+const payloadSeparator = '\x1e'
+
+// EncodePayload encodes packets into a single HTTP long-polling payload
+func EncodePayload(packets []*Packet) []byte {
+	size := 0
+	for _, packet := range packets {
+		size += len(packet.Data) + 2
+	}
+
+	result := make([]byte, 0, size)
+	for i, packet := range packets {
+		if i > 0 {
+			result = append(result, payloadSeparator)
+		}
+		result = append(result, packet.Encode()...)
+	}
+	return result
+}
+
+// DecodePayload decodes an HTTP long-polling payload into packets
+func DecodePayload(data []byte) ([]*Packet, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+
+	var packets []*Packet
+	for _, chunk := range bytes.Split(data, []byte{payloadSeparator}) {
+		packet, err := DecodePacket(chunk)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+	}
+	return packets, nil
+}
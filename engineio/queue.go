@@ -0,0 +1,184 @@
+package engineio
+
+import "sync"
+
+// DefaultMaxQueuedPackets is the default size of the outgoing queue of a
+// session, in packets
+const DefaultMaxQueuedPackets = 256
+
+// OverflowPolicy selects what happens to packets sent to a session whose
+// outgoing queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest rejects the packets being sent with ErrSlowClient
+	// (default).
+	OverflowDropNewest OverflowPolicy = iota
+
+	// OverflowDropOldest drops the oldest queued messages to make room for
+	// the packets being sent.
+	OverflowDropOldest
+
+	// OverflowBlock makes Send wait until the queue has room or the session
+	// closes, and SendContext until its context is done.
+	OverflowBlock
+
+	// OverflowDisconnect closes the session with reason "slow client" and
+	// rejects the packets being sent with ErrSlowClient.
+	OverflowDisconnect
+)
+
+// String returns the name of the policy
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropNewest:
+		return "drop newest"
+	case OverflowDropOldest:
+		return "drop oldest"
+	case OverflowBlock:
+		return "block"
+	case OverflowDisconnect:
+		return "disconnect"
+	default:
+		return "unknown"
+	}
+}
+
+// outgoingQueue holds the packets waiting to be written to a session.
+//
+// Packets sent together are queued as one entry and never split by the
+// overflow policies, so the binary attachments of a Socket.IO packet are
+// dropped along with it. Control packets such as pings are never dropped
+// and do not count toward the limits.
+type outgoingQueue struct {
+	mu      sync.Mutex
+	entries []queueEntry
+	packets int
+	bytes   int
+
+	// ready is signaled when packets are queued
+	ready chan struct{}
+
+	// space is closed and replaced when packets are taken or dropped
+	space chan struct{}
+}
+
+type queueEntry struct {
+	packets []*Packet
+	bytes   int
+	control bool
+}
+
+func newOutgoingQueue() *outgoingQueue {
+	return &outgoingQueue{
+		ready: make(chan struct{}, 1),
+		space: make(chan struct{}),
+	}
+}
+
+func newQueueEntry(packets []*Packet) queueEntry {
+	entry := queueEntry{packets: packets, control: true}
+	for _, packet := range packets {
+		entry.bytes += len(packet.Data) + 1
+		if packet.Type == PacketTypeMessage {
+			entry.control = false
+		}
+	}
+	return entry
+}
+
+// fits reports whether the entry can be queued within the limits. An entry
+// always fits an empty queue, or it could never be sent. The caller must
+// hold q.mu.
+func (q *outgoingQueue) fits(entry queueEntry, maxPackets, maxBytes int) bool {
+	if entry.control || q.packets == 0 {
+		return true
+	}
+	if q.packets+len(entry.packets) > maxPackets {
+		return false
+	}
+	return maxBytes <= 0 || q.bytes+entry.bytes <= maxBytes
+}
+
+// push queues the entry. The caller must hold q.mu.
+func (q *outgoingQueue) push(entry queueEntry) {
+	q.entries = append(q.entries, entry)
+	if !entry.control {
+		q.packets += len(entry.packets)
+		q.bytes += entry.bytes
+	}
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// dropOldest drops the oldest message entry and reports whether there was
+// one. The caller must hold q.mu.
+func (q *outgoingQueue) dropOldest() bool {
+	for i, entry := range q.entries {
+		if entry.control {
+			continue
+		}
+		q.entries = append(q.entries[:i:i], q.entries[i+1:]...)
+		q.packets -= len(entry.packets)
+		q.bytes -= entry.bytes
+		q.freed()
+		return true
+	}
+	return false
+}
+
+// pop removes and returns the packets of the oldest entry
+func (q *outgoingQueue) pop() []*Packet {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.entries) == 0 {
+		return nil
+	}
+
+	entry := q.entries[0]
+	q.entries = q.entries[1:]
+	if !entry.control {
+		q.packets -= len(entry.packets)
+		q.bytes -= entry.bytes
+	}
+	q.freed()
+
+	// Wake up the reader for the remaining entries
+	if len(q.entries) > 0 {
+		select {
+		case q.ready <- struct{}{}:
+		default:
+		}
+	}
+	return entry.packets
+}
+
+// take removes and returns all queued packets
+func (q *outgoingQueue) take() []*Packet {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.entries) == 0 {
+		return nil
+	}
+
+	var packets []*Packet
+	for _, entry := range q.entries {
+		packets = append(packets, entry.packets...)
+	}
+	q.entries = nil
+	q.packets = 0
+	q.bytes = 0
+	q.freed()
+	return packets
+}
+
+// freed wakes up the senders waiting for room. The caller must hold q.mu.
+func (q *outgoingQueue) freed() {
+	close(q.space)
+	q.space = make(chan struct{})
+}
@@ -0,0 +1,154 @@
+package engineio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// openPolling opens a polling session that is never polled, so that packets
+// sent to it stay queued, and returns it with its poll URL
+func openPolling(t *testing.T, config *Config) (*Session, string) {
+	t.Helper()
+
+	s := NewServer(config)
+	sessions := make(chan *Session, 1)
+	s.OnConnect(func(session *Session) { sessions <- session })
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	t.Cleanup(s.Close)
+
+	resp, err := http.Get(ts.URL + "/?EIO=4&transport=polling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	session := <-sessions
+	return session, ts.URL + "/?EIO=4&transport=polling&sid=" + session.ID()
+}
+
+func poll(t *testing.T, url string) string {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return strings.ReplaceAll(string(body), string(payloadSeparator), "|")
+}
+
+func message(data string) *Packet {
+	return &Packet{Type: PacketTypeMessage, Data: []byte(data)}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	var overflows atomic.Int32
+	session, url := openPolling(t, &Config{
+		MaxQueuedPackets: 2,
+		OnOverflow:       func(*Session, []*Packet) { overflows.Add(1) },
+	})
+
+	for _, data := range []string{"a", "b", "c"} {
+		err := session.Send(message(data))
+		if data == "c" && !errors.Is(err, ErrSlowClient) {
+			t.Fatalf("Send(%s) = %v, want ErrSlowClient", data, err)
+		}
+	}
+	// Control packets never overflow
+	if err := session.Send(&Packet{Type: PacketTypePing}); err != nil {
+		t.Fatalf("Send(ping) = %v", err)
+	}
+
+	if got := poll(t, url); got != "4a|4b|2" {
+		t.Fatalf("poll = %q, want %q", got, "4a|4b|2")
+	}
+	if overflows.Load() != 1 {
+		t.Fatalf("OnOverflow called %d times, want 1", overflows.Load())
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	session, url := openPolling(t, &Config{
+		MaxQueuedPackets: 3,
+		OverflowPolicy:   OverflowDropOldest,
+	})
+
+	for _, data := range []string{"a", "b", "c", "d"} {
+		if err := session.Send(message(data)); err != nil {
+			t.Fatalf("Send(%s) = %v", data, err)
+		}
+	}
+	// Packets sent together are dropped together
+	binary := &Packet{Type: PacketTypeMessage, Data: []byte{1}, Binary: true}
+	if err := session.SendContext(context.Background(), message("x"), binary); err != nil {
+		t.Fatalf("SendContext = %v", err)
+	}
+
+	if got := poll(t, url); got != "4d|4x|bAQ==" {
+		t.Fatalf("poll = %q, want %q", got, "4d|4x|bAQ==")
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	session, url := openPolling(t, &Config{
+		MaxQueuedBytes: 5,
+		OverflowPolicy: OverflowBlock,
+	})
+
+	if err := session.Send(message("1234")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := session.SendContext(ctx, message("5")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SendContext = %v, want context.DeadlineExceeded", err)
+	}
+
+	sent := make(chan error, 1)
+	go func() { sent <- session.Send(message("5")) }()
+
+	if got := poll(t, url); got != "41234" {
+		t.Fatalf("poll = %q, want %q", got, "41234")
+	}
+	select {
+	case err := <-sent:
+		if err != nil {
+			t.Fatalf("Send = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send still blocked after the queue was flushed")
+	}
+	if got := poll(t, url); got != "45" {
+		t.Fatalf("poll = %q, want %q", got, "45")
+	}
+}
+
+func TestOverflowDisconnect(t *testing.T) {
+	session, _ := openPolling(t, &Config{
+		MaxQueuedPackets: 1,
+		OverflowPolicy:   OverflowDisconnect,
+	})
+
+	reasons := make(chan string, 1)
+	session.OnClose(func(reason string) { reasons <- reason })
+
+	session.Send(message("a"))
+	if err := session.Send(message("b")); !errors.Is(err, ErrSlowClient) {
+		t.Fatalf("Send = %v, want ErrSlowClient", err)
+	}
+	if reason := <-reasons; reason != "slow client" {
+		t.Fatalf("closed with %q, want %q", reason, "slow client")
+	}
+}
@@ -20,6 +20,60 @@ type Config struct {
 	PingInterval int // milliseconds
 	PingTimeout  int // milliseconds
 	MaxPayload   int // bytes
+
+	// AllowedOrigins lists the cross-origin browsers allowed to connect,
+	// either exact ("https://example.com"), wildcard subdomains
+	// ("https://*.example.com"), with "*" as port for any port, or "*" for
+	// any origin. Same-origin requests and requests without an Origin header
+	// are always allowed; other origins are rejected unless listed here or
+	// allowed by CheckOrigin.
+	AllowedOrigins []string
+
+	// CheckOrigin is called for origins not matched by AllowedOrigins and
+	// reports whether the request is allowed
+	CheckOrigin func(r *http.Request) bool
+
+	// AllowCredentials allows browsers to send cookies and HTTP auth with
+	// cross-origin requests from origins matched by an AllowedOrigins entry
+	// other than "*" or by CheckOrigin
+	AllowCredentials bool
+
+	// AllowedHeaders lists the request headers allowed in cross-origin
+	// requests. If empty, the headers requested by the preflight are allowed.
+	AllowedHeaders []string
+
+	// CORSMaxAge is how long browsers may cache preflight results, in seconds
+	CORSMaxAge int
+
+	// AllowRequest is called for every handshake request before a session
+	// is created or the connection upgraded. Returning an error rejects the
+	// request with a Forbidden error whose message is the error text.
+	AllowRequest func(r *http.Request) error
+
+	// Clock schedules ping timers. If nil, the time package is used.
+	Clock Clock
+
+	// AllowEIO3 accepts clients speaking Engine.IO v3, such as
+	// socket.io-client 2.x, in addition to v4 clients. v3 clients send the
+	// pings and use a different long-polling payload framing.
+	AllowEIO3 bool
+
+	// MaxQueuedPackets is the number of message packets queued per session
+	// before OverflowPolicy applies (default: 256)
+	MaxQueuedPackets int
+
+	// MaxQueuedBytes is the size of the message packets queued per session
+	// before OverflowPolicy applies. The queue size is unlimited if zero.
+	MaxQueuedBytes int
+
+	// OverflowPolicy selects what happens to packets sent to a session whose
+	// queue is full (default: OverflowDropNewest)
+	OverflowPolicy OverflowPolicy
+
+	// OnOverflow is called with the packets being sent whenever a session
+	// queue is full, which makes it suitable for counting slow clients. It
+	// is called once per send, from the sending goroutine, and must not block.
+	OnOverflow func(session *Session, packets []*Packet)
 }
 
 // DefaultConfig returns default Engine.IO configuration
@@ -28,6 +82,8 @@ func DefaultConfig() *Config {
 		PingInterval: 25000, // 25 seconds
 		PingTimeout:  20000, // 20 seconds
 		MaxPayload:   1e6,   // 1MB
+
+		MaxQueuedPackets: DefaultMaxQueuedPackets,
 	}
 }
 
@@ -45,23 +101,105 @@ func NewServer(config *Config) *Server {
 		config = DefaultConfig()
 	}
 
-	return &Server{
+	// Fill unset values with defaults
+	defaults := DefaultConfig()
+	if config.PingInterval <= 0 {
+		config.PingInterval = defaults.PingInterval
+	}
+	if config.PingTimeout <= 0 {
+		config.PingTimeout = defaults.PingTimeout
+	}
+	if config.MaxPayload <= 0 {
+		config.MaxPayload = defaults.MaxPayload
+	}
+	if config.MaxQueuedPackets <= 0 {
+		config.MaxQueuedPackets = defaults.MaxQueuedPackets
+	}
+
+	s := &Server{
 		config: config,
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // TODO: Make configurable
-			},
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
 		},
 	}
+	s.upgrader.CheckOrigin = s.checkOrigin
+
+	return s
 }
 
-// ServeHTTP handles HTTP requests and upgrades to WebSocket
+// ServeHTTP handles Engine.IO requests over both the WebSocket and the
+// HTTP long-polling transports
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Only handle WebSocket upgrade
-	if r.URL.Query().Get("transport") != "websocket" {
-		http.Error(w, "Only WebSocket transport is supported", http.StatusBadRequest)
+	if !s.checkOrigin(r) {
+		writeError(w, ErrorForbidden, "")
+		return
+	}
+
+	s.setCORSHeaders(w, r)
+
+	if r.Method == http.MethodOptions {
+		s.servePreflight(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	sid := query.Get("sid")
+	transport := query.Get("transport")
+
+	if transport != TransportWebSocket && transport != TransportPolling {
+		writeError(w, ErrorTransportUnknown, "")
+		return
+	}
+
+	if sid == "" {
+		if code, message, ok := s.verifyHandshake(r, transport); !ok {
+			writeError(w, code, message)
+			return
+		}
+	}
+
+	if transport == TransportWebSocket {
+		if !websocket.IsWebSocketUpgrade(r) {
+			writeError(w, ErrorBadRequest, "")
+			return
+		}
+		s.serveWebSocket(w, r, sid)
+		return
+	}
+
+	s.servePolling(w, r, sid)
+}
+
+// verifyHandshake checks a request opening a new session, running the
+// AllowRequest hook last so rejected handshakes never reach the upgrade
+func (s *Server) verifyHandshake(r *http.Request, transport string) (ErrorCode, string, bool) {
+	switch r.URL.Query().Get("EIO") {
+	case "4":
+	case "3":
+		if !s.config.AllowEIO3 {
+			return ErrorUnsupportedProtocolVersion, "", false
+		}
+	default:
+		return ErrorUnsupportedProtocolVersion, "", false
+	}
+
+	if transport == TransportPolling && r.Method != http.MethodGet {
+		return ErrorBadHandshakeMethod, "", false
+	}
+
+	if s.config.AllowRequest != nil {
+		if err := s.config.AllowRequest(r); err != nil {
+			return ErrorForbidden, err.Error(), false
+		}
+	}
+
+	return 0, "", true
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request, sid string) {
+	if sid != "" {
+		s.serveUpgrade(w, r, sid)
 		return
 	}
 
@@ -70,32 +208,119 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sid := generateSID()
+	sid = generateSID()
 	session := NewSession(sid, conn, s)
+	session.setRequest(r)
 
-	s.sessions.Store(sid, session)
-
-	// Send handshake
-	handshake, err := EncodeHandshake(sid, s.config.PingInterval, s.config.PingTimeout, s.config.MaxPayload)
+	handshake, err := s.handshake(session)
 	if err != nil {
 		conn.Close()
 		return
 	}
 
 	if err := conn.WriteMessage(websocket.TextMessage, handshake); err != nil {
+		s.sessions.Delete(sid)
 		conn.Close()
 		return
 	}
 
-	session.OnClose(func(reason string) {
-		s.sessions.Delete(sid)
-	})
+	s.open(session)
+}
 
-	session.Start()
+func (s *Server) servePolling(w http.ResponseWriter, r *http.Request, sid string) {
+	if sid == "" {
+		session := newPollingSession(generateSID(), s)
+		session.setRequest(r)
+
+		handshake, err := s.handshake(session)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		s.open(session)
+
+		// Engine.IO v3 frames the handshake like any other payload
+		if session.Protocol() == ProtocolV3 {
+			handshake = appendFrameV3(nil, handshake)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.Write(handshake)
+		return
+	}
+
+	session, ok := s.GetSession(sid)
+	if !ok {
+		writeError(w, ErrorUnknownSID, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if session.Transport() != TransportPolling {
+			writeError(w, ErrorBadRequest, "")
+			return
+		}
+		session.servePoll(w, r)
+	case http.MethodPost:
+		session.serveData(w, r)
+	default:
+		writeError(w, ErrorBadRequest, "")
+	}
+}
+
+// serveUpgrade upgrades an existing polling session to WebSocket
+func (s *Server) serveUpgrade(w http.ResponseWriter, r *http.Request, sid string) {
+	session, ok := s.GetSession(sid)
+	if !ok {
+		writeError(w, ErrorUnknownSID, "")
+		return
+	}
+
+	if session.Transport() != TransportPolling {
+		writeError(w, ErrorBadRequest, "")
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	session.upgrade(conn)
+}
+
+// handshake registers the session and returns its encoded open packet
+func (s *Server) handshake(session *Session) ([]byte, error) {
+	data := HandshakeData{
+		SID:          session.ID(),
+		Upgrades:     []string{},
+		PingInterval: s.config.PingInterval,
+		PingTimeout:  s.config.PingTimeout,
+		MaxPayload:   s.config.MaxPayload,
+	}
+	if session.Transport() == TransportPolling {
+		data.Upgrades = []string{TransportWebSocket}
+	}
 
+	handshake, err := data.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	s.sessions.Store(session.ID(), session)
+	return handshake, nil
+}
+
+// open hands the session to the connection handler and starts it, so the
+// handler is installed before the first packet is read
+func (s *Server) open(session *Session) {
 	if s.onConnect != nil {
 		s.onConnect(session)
 	}
+
+	session.Start()
 }
 
 // OnConnect sets the connection handler
@@ -1,40 +1,70 @@
 package engineio
 
 import (
+	"context"
+	"io"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// Transport names as sent in the "transport" query parameter
+const (
+	TransportPolling   = "polling"
+	TransportWebSocket = "websocket"
+)
+
 // Session represents an Engine.IO session
 type Session struct {
-	id            string
-	conn          *websocket.Conn
-	server        *Server
-	outgoing      chan *Packet
-	pingTimer     *time.Timer
-	pingTimeout   *time.Timer
-	closeOnce     sync.Once
-	closed        chan struct{}
-	mu            sync.RWMutex
-	onMessage     func([]byte)
-	onClose       func(string)
-	lastActivity  time.Time
-}
-
-// NewSession creates a new Engine.IO session
+	id           string
+	protocol     int
+	request      *http.Request
+	transport    string
+	conn         *websocket.Conn
+	writeMu      sync.Mutex
+	pollMu       sync.Mutex
+	server       *Server
+	queue        *outgoingQueue
+	upgraded     chan struct{}
+	upgrading    atomic.Bool
+	timerMu      sync.Mutex
+	pingTimer    Timer
+	pingTimeout  Timer
+	closeOnce    sync.Once
+	closed       chan struct{}
+	mu           sync.RWMutex
+	onMessage    func([]byte)
+	onBinary     func([]byte)
+	onClose      func(string)
+	lastActivity time.Time
+}
+
+// NewSession creates a new Engine.IO session over a WebSocket connection
 func NewSession(id string, conn *websocket.Conn, server *Server) *Session {
-	s := &Session{
+	s := newSession(id, TransportWebSocket, server)
+	s.conn = conn
+	return s
+}
+
+// newPollingSession creates a new Engine.IO session over HTTP long-polling
+func newPollingSession(id string, server *Server) *Session {
+	return newSession(id, TransportPolling, server)
+}
+
+func newSession(id, transport string, server *Server) *Session {
+	return &Session{
 		id:           id,
-		conn:         conn,
+		protocol:     ProtocolV4,
+		transport:    transport,
 		server:       server,
-		outgoing:     make(chan *Packet, 256),
+		queue:        newOutgoingQueue(),
+		upgraded:     make(chan struct{}),
 		closed:       make(chan struct{}),
-		lastActivity: time.Now(),
+		lastActivity: server.clock().Now(),
 	}
-
-	return s
 }
 
 // ID returns the session ID
@@ -42,23 +72,124 @@ func (s *Session) ID() string {
 	return s.id
 }
 
+// Request returns the HTTP request that opened the session.
+//
+// The request body is not available.
+func (s *Session) Request() *http.Request {
+	return s.request
+}
+
+// Protocol returns the Engine.IO protocol revision spoken by the client,
+// ProtocolV3 or ProtocolV4
+func (s *Session) Protocol() int {
+	return s.protocol
+}
+
+// setRequest keeps a copy of the handshake request, detached from its body
+// and from the lifetime of the HTTP exchange, and the protocol it asked for
+func (s *Session) setRequest(r *http.Request) {
+	request := r.Clone(context.Background())
+	request.Body = http.NoBody
+	s.request = request
+
+	if r.URL.Query().Get("EIO") == "3" {
+		s.protocol = ProtocolV3
+	}
+}
+
+// Transport returns the name of the transport currently used by the session
+func (s *Session) Transport() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.transport
+}
+
 // Start starts the session loops
 func (s *Session) Start() {
-	go s.writeLoop()
-	go s.readLoop()
+	if s.conn != nil {
+		go s.writeLoop()
+		go s.readLoop()
+	}
+
+	// Engine.IO v3 clients send the pings
+	if s.protocol == ProtocolV3 {
+		s.schedulePingTimeoutV3()
+		return
+	}
 	s.schedulePing()
 }
 
-// Send sends a packet to the client
+// Send sends a packet to the client. If the outgoing queue of the session is
+// full, the configured OverflowPolicy is applied.
 func (s *Session) Send(packet *Packet) error {
-	select {
-	case s.outgoing <- packet:
-		return nil
-	case <-s.closed:
-		return ErrSessionClosed
-	default:
-		// Channel full, connection might be slow
-		return ErrSlowClient
+	return s.SendContext(context.Background(), packet)
+}
+
+// SendContext queues packets to be sent to the client together. If the
+// outgoing queue of the session is full, Config.OnOverflow is called and the
+// configured OverflowPolicy is applied; with OverflowBlock, SendContext waits
+// until the queue has room, the session closes or ctx is done.
+//
+// The packets are never split: they are all queued, all dropped, or all
+// rejected.
+func (s *Session) SendContext(ctx context.Context, packets ...*Packet) error {
+	config := s.server.config
+	entry := newQueueEntry(packets)
+	reported := false
+
+	for {
+		s.queue.mu.Lock()
+		if s.isClosed() {
+			s.queue.mu.Unlock()
+			return ErrSessionClosed
+		}
+
+		if s.queue.fits(entry, config.MaxQueuedPackets, config.MaxQueuedBytes) {
+			s.queue.push(entry)
+			s.queue.mu.Unlock()
+			return nil
+		}
+
+		if config.OverflowPolicy == OverflowDropOldest {
+			// An empty queue always fits, so this ends
+			for !s.queue.fits(entry, config.MaxQueuedPackets, config.MaxQueuedBytes) {
+				s.queue.dropOldest()
+			}
+			s.queue.push(entry)
+			s.queue.mu.Unlock()
+			s.reportOverflow(packets)
+			return nil
+		}
+
+		space := s.queue.space
+		s.queue.mu.Unlock()
+
+		if !reported {
+			s.reportOverflow(packets)
+			reported = true
+		}
+
+		switch config.OverflowPolicy {
+		case OverflowBlock:
+			select {
+			case <-space:
+			case <-s.closed:
+				return ErrSessionClosed
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case OverflowDisconnect:
+			s.Close("slow client")
+			return ErrSlowClient
+		default:
+			return ErrSlowClient
+		}
+	}
+}
+
+func (s *Session) reportOverflow(packets []*Packet) {
+	if s.server.config.OnOverflow != nil {
+		s.server.config.OnOverflow(s, packets)
 	}
 }
 
@@ -66,22 +197,28 @@ func (s *Session) Send(packet *Packet) error {
 func (s *Session) Close(reason string) {
 	s.closeOnce.Do(func() {
 		close(s.closed)
+		s.stopTimers()
 
-		if s.pingTimer != nil {
-			s.pingTimer.Stop()
-		}
-		if s.pingTimeout != nil {
-			s.pingTimeout.Stop()
+		// Send close packet. Polling clients receive it from the pending
+		// poll request, if any.
+		s.mu.RLock()
+		conn := s.conn
+		s.mu.RUnlock()
+
+		if conn != nil {
+			packet := &Packet{Type: PacketTypeClose}
+			s.writeMessage(websocket.TextMessage, packet.Encode())
+			conn.Close()
 		}
 
-		// Send close packet
-		packet := &Packet{Type: PacketTypeClose}
-		s.conn.WriteMessage(websocket.TextMessage, packet.Encode())
+		s.server.sessions.Delete(s.id)
 
-		s.conn.Close()
+		s.mu.RLock()
+		handler := s.onClose
+		s.mu.RUnlock()
 
-		if s.onClose != nil {
-			s.onClose(reason)
+		if handler != nil {
+			handler(reason)
 		}
 	})
 }
@@ -93,6 +230,13 @@ func (s *Session) OnMessage(fn func([]byte)) {
 	s.mu.Unlock()
 }
 
+// OnBinaryMessage sets the handler for binary message packets
+func (s *Session) OnBinaryMessage(fn func([]byte)) {
+	s.mu.Lock()
+	s.onBinary = fn
+	s.mu.Unlock()
+}
+
 // OnClose sets the close handler
 func (s *Session) OnClose(fn func(string)) {
 	s.mu.Lock()
@@ -104,13 +248,25 @@ func (s *Session) readLoop() {
 	defer s.Close("read error")
 
 	for {
-		_, data, err := s.conn.ReadMessage()
+		messageType, data, err := s.conn.ReadMessage()
 		if err != nil {
 			return
 		}
 
 		s.updateActivity()
 
+		if messageType == websocket.BinaryMessage {
+			// Engine.IO v3 binary frames start with the packet type
+			if s.protocol == ProtocolV3 {
+				if len(data) == 0 || data[0] != byte(PacketTypeMessage) {
+					continue
+				}
+				data = data[1:]
+			}
+			s.handlePacket(&Packet{Type: PacketTypeMessage, Data: data, Binary: true})
+			continue
+		}
+
 		packet, err := DecodePacket(data)
 		if err != nil {
 			continue
@@ -123,10 +279,12 @@ func (s *Session) readLoop() {
 func (s *Session) writeLoop() {
 	for {
 		select {
-		case packet := <-s.outgoing:
-			if err := s.conn.WriteMessage(websocket.TextMessage, packet.Encode()); err != nil {
-				s.Close("write error")
-				return
+		case <-s.queue.ready:
+			for _, packet := range s.queue.pop() {
+				if err := s.writePacket(packet); err != nil {
+					s.Close("write error")
+					return
+				}
 			}
 		case <-s.closed:
 			return
@@ -134,55 +292,287 @@ func (s *Session) writeLoop() {
 	}
 }
 
+func (s *Session) writePacket(packet *Packet) error {
+	switch {
+	case packet.Binary && s.protocol == ProtocolV3:
+		// Engine.IO v3 binary frames start with the packet type
+		data := append([]byte{byte(PacketTypeMessage)}, packet.Data...)
+		return s.writeMessage(websocket.BinaryMessage, data)
+	case packet.Binary:
+		return s.writeMessage(websocket.BinaryMessage, packet.Data)
+	default:
+		return s.writeMessage(websocket.TextMessage, packet.Encode())
+	}
+}
+
+func (s *Session) writeMessage(messageType int, data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.conn.WriteMessage(messageType, data)
+}
+
+// servePoll answers a long-polling GET request with the queued packets,
+// blocking until at least one packet is available or the session closes.
+func (s *Session) servePoll(w http.ResponseWriter, r *http.Request) {
+	if !s.pollMu.TryLock() {
+		// Overlapping poll requests are a protocol violation
+		writeError(w, ErrorBadRequest, "")
+		s.Close("transport error")
+		return
+	}
+	defer s.pollMu.Unlock()
+
+	// Flush everything queued once at least one packet is available
+	var packets []*Packet
+	for len(packets) == 0 {
+		select {
+		case <-s.queue.ready:
+			packets = s.queue.take()
+		case <-s.closed:
+			packets = []*Packet{{Type: PacketTypeClose}}
+		case <-s.upgraded:
+			// Queued packets now belong to the WebSocket transport
+			packets = []*Packet{{Type: PacketTypeNoop}}
+		case <-r.Context().Done():
+			s.Close("transport close")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	if s.protocol == ProtocolV3 {
+		w.Write(encodePayloadV3(packets))
+		return
+	}
+	w.Write(EncodePayload(packets))
+}
+
+// serveData handles a long-polling POST request carrying client packets.
+func (s *Session) serveData(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(s.server.config.MaxPayload)))
+	if err != nil {
+		writeError(w, ErrorBadRequest, "")
+		s.Close("transport error")
+		return
+	}
+
+	packets, err := s.decodePayload(r, body)
+	if err != nil {
+		writeError(w, ErrorBadRequest, "")
+		s.Close("parse error")
+		return
+	}
+
+	s.updateActivity()
+
+	for _, packet := range packets {
+		s.handlePacket(packet)
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte("ok"))
+}
+
+// decodePayload decodes the body of a long-polling POST request
+func (s *Session) decodePayload(r *http.Request, body []byte) ([]*Packet, error) {
+	if s.protocol != ProtocolV3 {
+		return DecodePayload(body)
+	}
+	if r.Header.Get("Content-Type") == "application/octet-stream" {
+		return decodeBinaryPayloadV3(body)
+	}
+	return decodePayloadV3(body)
+}
+
+// upgrade runs the probe handshake on conn and, once the client sends the
+// upgrade packet, moves the session from polling to WebSocket. Packets
+// queued are kept and flushed over the new connection.
+func (s *Session) upgrade(conn *websocket.Conn) {
+	if !s.upgrading.CompareAndSwap(false, true) {
+		conn.Close()
+		return
+	}
+	defer s.upgrading.Store(false)
+
+	timeout := time.Duration(s.server.config.PingTimeout) * time.Millisecond
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			conn.Close()
+			return
+		}
+
+		packet, err := DecodePacket(data)
+		if err != nil {
+			conn.Close()
+			return
+		}
+
+		switch {
+		case packet.Type == PacketTypePing && string(packet.Data) == "probe":
+			pong := &Packet{Type: PacketTypePong, Data: []byte("probe")}
+			if err := conn.WriteMessage(websocket.TextMessage, pong.Encode()); err != nil {
+				conn.Close()
+				return
+			}
+
+			// Release the pending poll so the client can pause polling
+			s.Send(&Packet{Type: PacketTypeNoop})
+
+		case packet.Type == PacketTypeUpgrade:
+			conn.SetReadDeadline(time.Time{})
+			s.switchTransport(conn)
+			return
+
+		default:
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (s *Session) switchTransport(conn *websocket.Conn) {
+	close(s.upgraded)
+
+	// Wait for any in-flight poll to return before handing over the queue
+	s.pollMu.Lock()
+	defer s.pollMu.Unlock()
+
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	s.conn = conn
+	s.transport = TransportWebSocket
+	s.mu.Unlock()
+
+	go s.writeLoop()
+	go s.readLoop()
+}
+
 func (s *Session) handlePacket(packet *Packet) {
 	switch packet.Type {
 	case PacketTypePing:
-		s.handlePing()
+		s.handlePing(packet)
 	case PacketTypePong:
 		s.handlePong()
 	case PacketTypeMessage:
-		s.handleMessage(packet.Data)
+		s.handleMessage(packet)
 	case PacketTypeClose:
 		s.Close("client closed")
 	}
 }
 
-func (s *Session) handlePing() {
+func (s *Session) handlePing(packet *Packet) {
+	if s.protocol == ProtocolV3 {
+		s.schedulePingTimeoutV3()
+		s.Send(&Packet{Type: PacketTypePong, Data: packet.Data})
+		return
+	}
 	s.Send(&Packet{Type: PacketTypePong})
 }
 
 func (s *Session) handlePong() {
-	if s.pingTimeout != nil {
-		s.pingTimeout.Stop()
+	if s.protocol == ProtocolV3 {
+		return
 	}
 	s.schedulePing()
 }
 
-func (s *Session) handleMessage(data []byte) {
+func (s *Session) handleMessage(packet *Packet) {
 	s.mu.RLock()
 	handler := s.onMessage
+	if packet.Binary {
+		handler = s.onBinary
+	}
 	s.mu.RUnlock()
 
 	if handler != nil {
-		handler(data)
+		handler(packet.Data)
 	}
 }
 
+// schedulePing stops the pending timers and schedules the next ping
 func (s *Session) schedulePing() {
-	s.pingTimer = time.AfterFunc(time.Duration(s.server.config.PingInterval)*time.Millisecond, func() {
+	s.timerMu.Lock()
+	defer s.timerMu.Unlock()
+
+	if s.stopTimersLocked() {
+		return
+	}
+
+	interval := time.Duration(s.server.config.PingInterval) * time.Millisecond
+	s.pingTimer = s.server.clock().AfterFunc(interval, func() {
 		s.Send(&Packet{Type: PacketTypePing})
 		s.schedulePingTimeout()
 	})
 }
 
 func (s *Session) schedulePingTimeout() {
-	s.pingTimeout = time.AfterFunc(time.Duration(s.server.config.PingTimeout)*time.Millisecond, func() {
+	s.timerMu.Lock()
+	defer s.timerMu.Unlock()
+
+	if s.isClosed() {
+		return
+	}
+
+	timeout := time.Duration(s.server.config.PingTimeout) * time.Millisecond
+	s.pingTimeout = s.server.clock().AfterFunc(timeout, func() {
 		s.Close("ping timeout")
 	})
 }
 
+// schedulePingTimeoutV3 stops the pending timers and closes the session
+// unless the client pings again within the ping interval and timeout
+func (s *Session) schedulePingTimeoutV3() {
+	s.timerMu.Lock()
+	defer s.timerMu.Unlock()
+
+	if s.stopTimersLocked() {
+		return
+	}
+
+	timeout := time.Duration(s.server.config.PingInterval+s.server.config.PingTimeout) * time.Millisecond
+	s.pingTimeout = s.server.clock().AfterFunc(timeout, func() {
+		s.Close("ping timeout")
+	})
+}
+
+func (s *Session) stopTimers() {
+	s.timerMu.Lock()
+	defer s.timerMu.Unlock()
+	s.stopTimersLocked()
+}
+
+// stopTimersLocked stops the ping timers and reports whether the session is
+// closed. The caller must hold s.timerMu.
+func (s *Session) stopTimersLocked() bool {
+	if s.pingTimer != nil {
+		s.pingTimer.Stop()
+	}
+	if s.pingTimeout != nil {
+		s.pingTimeout.Stop()
+	}
+	return s.isClosed()
+}
+
+func (s *Session) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
 func (s *Session) updateActivity() {
 	s.mu.Lock()
-	s.lastActivity = time.Now()
+	s.lastActivity = s.server.clock().Now()
 	s.mu.Unlock()
 }
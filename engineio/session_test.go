@@ -0,0 +1,177 @@
+package engineio
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// pollAsync polls in the background and returns the response body with
+// separators shown as "|", or the status if the request failed
+func pollAsync(url string) <-chan string {
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			result <- resp.Status
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		result <- strings.ReplaceAll(string(body), string(payloadSeparator), "|")
+	}()
+	return result
+}
+
+func receive(t *testing.T, result <-chan string) string {
+	t.Helper()
+
+	select {
+	case got := <-result:
+		return got
+	case <-time.After(time.Second):
+		t.Fatal("poll still pending")
+		return ""
+	}
+}
+
+func TestPollingPayload(t *testing.T) {
+	session, url := openPolling(t, nil)
+
+	messages := make(chan string, 3)
+	session.OnMessage(func(data []byte) { messages <- string(data) })
+	session.OnBinaryMessage(func(data []byte) { messages <- string(data) })
+
+	resp, err := http.Post(url, "text/plain", strings.NewReader("4hello\x1e4wörld\x1ebAQI="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for _, want := range []string{"hello", "wörld", "\x01\x02"} {
+		if got := <-messages; got != want {
+			t.Fatalf("received %q, want %q", got, want)
+		}
+	}
+
+	session.Send(message("a"))
+	session.Send(&Packet{Type: PacketTypeMessage, Data: []byte{1, 2}, Binary: true})
+	session.Send(message("b"))
+	if got := poll(t, url); got != "4a|bAQI=|4b" {
+		t.Fatalf("poll = %q, want %q", got, "4a|bAQI=|4b")
+	}
+}
+
+func TestConcurrentPollRejected(t *testing.T) {
+	session, url := openPolling(t, nil)
+
+	reasons := make(chan string, 1)
+	session.OnClose(func(reason string) { reasons <- reason })
+
+	first := pollAsync(url)
+	time.Sleep(20 * time.Millisecond)
+	second := pollAsync(url)
+
+	// One poll is refused and the session closes, ending the other one
+	results := []string{receive(t, first), receive(t, second)}
+	if !(results[0] == "1" && results[1] == "400 Bad Request") && !(results[0] == "400 Bad Request" && results[1] == "1") {
+		t.Fatalf("polls = %q, want a refused poll and a CLOSE packet", results)
+	}
+	if reason := <-reasons; reason != "transport error" {
+		t.Fatalf("closed with %q, want %q", reason, "transport error")
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	session, url := openPolling(t, nil)
+
+	messages := make(chan string, 1)
+	session.OnMessage(func(data []byte) { messages <- string(data) })
+
+	pending := pollAsync(url)
+	time.Sleep(20 * time.Millisecond)
+
+	wsURL := "ws" + strings.TrimPrefix(strings.Replace(url, TransportPolling, TransportWebSocket, 1), "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	// The probe releases the pending poll with a noop
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("2probe")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "3probe" {
+		t.Fatalf("got %q (%v), want the probe pong", data, err)
+	}
+	if got := receive(t, pending); got != "6" {
+		t.Fatalf("pending poll = %q, want a noop", got)
+	}
+	if session.Transport() != TransportPolling {
+		t.Fatalf("transport = %q before the upgrade packet", session.Transport())
+	}
+
+	// Packets queued during the upgrade are flushed over the WebSocket
+	session.Send(message("a"))
+	session.Send(&Packet{Type: PacketTypeMessage, Data: []byte{1, 2}, Binary: true})
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("5")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "4a" {
+		t.Fatalf("got %q (%v), want the queued message", data, err)
+	}
+	if kind, data, err := conn.ReadMessage(); err != nil || kind != websocket.BinaryMessage || !bytes.Equal(data, []byte{1, 2}) {
+		t.Fatalf("got %v (%v), want the queued binary message", data, err)
+	}
+	if session.Transport() != TransportWebSocket {
+		t.Fatalf("transport = %q after the upgrade", session.Transport())
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("4hi")); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-messages; got != "hi" {
+		t.Fatalf("received %q, want %q", got, "hi")
+	}
+
+	// Polling is over
+	if got := receive(t, pollAsync(url)); got != "400 Bad Request" {
+		t.Fatalf("poll after the upgrade = %q, want it refused", got)
+	}
+}
+
+func TestUpgradeWithoutPendingPoll(t *testing.T) {
+	session, url := openPolling(t, nil)
+
+	wsURL := "ws" + strings.TrimPrefix(strings.Replace(url, TransportPolling, TransportWebSocket, 1), "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	session.Send(message("before"))
+	conn.WriteMessage(websocket.TextMessage, []byte("2probe"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "3probe" {
+		t.Fatalf("got %q (%v), want the probe pong", data, err)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("5"))
+
+	// Nothing polled: the queue, noop included, moves to the WebSocket
+	for _, want := range []string{"4before", "6"} {
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != want {
+			t.Fatalf("got %q (%v), want %q", data, err, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gosocketio
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Handshake holds the details of the connection that opened a socket.
+//
+// It combines the HTTP request that opened the underlying Engine.IO session
+// with the auth payload the client sent when connecting to the namespace.
+// A Handshake must not be modified.
+//
+// Example:
+//
+//	h := socket.Handshake()
+//	tenant := h.Headers.Get("X-Tenant")
+//	if cookie, err := h.Cookie("session"); err == nil {
+//	    log.Printf("Session cookie: %s", cookie.Value)
+//	}
+type Handshake struct {
+	// Headers are the HTTP request headers.
+	Headers http.Header
+
+	// Query holds the query string parameters.
+	Query url.Values
+
+	// Address is the remote address of the client, as reported by
+	// http.Request.RemoteAddr.
+	Address string
+
+	// URL is the request URL.
+	URL *url.URL
+
+	// TLS is the state of the TLS connection, or nil for plain-text connections.
+	TLS *tls.ConnectionState
+
+	// Time is when the socket connected to the namespace.
+	Time time.Time
+
+	// Auth is the auth payload sent by the client in its CONNECT packet, or
+	// nil if none was sent.
+	Auth map[string]interface{}
+}
+
+func newHandshake(r *http.Request, auth map[string]interface{}, now time.Time) *Handshake {
+	h := &Handshake{
+		Time: now,
+		Auth: auth,
+	}
+
+	if r != nil {
+		h.Headers = r.Header
+		h.Query = r.URL.Query()
+		h.Address = r.RemoteAddr
+		h.URL = r.URL
+		h.TLS = r.TLS
+	}
+
+	return h
+}
+
+// Cookies returns the cookies sent with the request.
+func (h *Handshake) Cookies() []*http.Cookie {
+	r := http.Request{Header: h.Headers}
+	return r.Cookies()
+}
+
+// Cookie returns the named cookie sent with the request, or
+// http.ErrNoCookie if it was not sent.
+func (h *Handshake) Cookie(name string) (*http.Cookie, error) {
+	for _, cookie := range h.Cookies() {
+		if cookie.Name == name {
+			return cookie, nil
+		}
+	}
+	return nil, http.ErrNoCookie
+}
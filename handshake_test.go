@@ -0,0 +1,143 @@
+package gosocketio_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+func TestAllowRequest(t *testing.T) {
+	server := siotest.NewServer(t, &gosocketio.Config{
+		AllowRequest: func(r *http.Request) error {
+			if r.Header.Get("X-Token") != "secret" {
+				return errors.New("missing token")
+			}
+			return nil
+		},
+	})
+	httpClient := server.Options().HTTPClient
+
+	tests := []struct {
+		name        string
+		method      string
+		query       string
+		token       string
+		wantStatus  int
+		wantCode    int
+		wantMessage string
+	}{
+		{"rejected", http.MethodGet, "EIO=4&transport=polling", "", http.StatusForbidden, 4, "missing token"},
+		{"unknown transport", http.MethodGet, "EIO=4&transport=carrier-pigeon", "secret", http.StatusBadRequest, 0, "Transport unknown"},
+		{"unknown session", http.MethodGet, "EIO=4&transport=polling&sid=nope", "secret", http.StatusBadRequest, 1, "Session ID unknown"},
+		{"bad method", http.MethodPost, "EIO=4&transport=polling", "secret", http.StatusBadRequest, 2, "Bad handshake method"},
+		{"unsupported version", http.MethodGet, "EIO=3&transport=polling", "secret", http.StatusBadRequest, 5, "Unsupported protocol version"},
+		// The protocol is checked before the request is passed to the hook
+		{"unsupported version rejected", http.MethodGet, "EIO=5&transport=polling", "", http.StatusBadRequest, 5, "Unsupported protocol version"},
+		{"allowed", http.MethodGet, "EIO=4&transport=polling", "secret", http.StatusOK, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, "http://siotest/socket.io/?"+tt.query, strings.NewReader(""))
+			if tt.token != "" {
+				req.Header.Set("X-Token", tt.token)
+			}
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("invalid error body: %v", err)
+			}
+			if body.Code != tt.wantCode || body.Message != tt.wantMessage {
+				t.Fatalf("got error %+v, want code %d and message %q", body, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+
+	// WebSocket handshakes are rejected before the upgrade
+	_, resp, err := server.Options().Dialer.Dial("ws://siotest/socket.io/?EIO=4&transport=websocket", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("WebSocket dial = %v, want a Forbidden response", err)
+	}
+
+	if _, err := server.Dial("/", nil); err == nil {
+		t.Fatal("client connected without the token")
+	}
+	opts := server.Options()
+	opts.Header = http.Header{"X-Token": {"secret"}}
+	if _, err := server.Dial("/", opts); err != nil {
+		t.Fatalf("Dial with the token = %v", err)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	handshakes := make(chan *gosocketio.Handshake, 2)
+	server.OnConnect(func(socket *gosocketio.Socket) { handshakes <- socket.Handshake() })
+	server.Of("/chat").OnConnect(func(socket *gosocketio.Socket) { handshakes <- socket.Handshake() })
+
+	opts := server.Options()
+	opts.Header = http.Header{
+		"X-Tenant": {"acme"},
+		"Cookie":   {"session=abc; theme=dark"},
+	}
+	opts.Query = url.Values{"room": {"lobby"}}
+	opts.Auth = map[string]interface{}{"token": "secret"}
+	alice, err := server.Dial("/", opts)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	h := <-handshakes
+	if h.Headers.Get("X-Tenant") != "acme" || h.Query.Get("room") != "lobby" {
+		t.Fatalf("got headers %v and query %v", h.Headers, h.Query)
+	}
+	if h.URL == nil || h.URL.Path != "/socket.io/" || h.Address == "" || h.TLS != nil {
+		t.Fatalf("got URL %v, address %q and TLS %v", h.URL, h.Address, h.TLS)
+	}
+	if h.Auth["token"] != "secret" {
+		t.Fatalf("got auth %v, want the CONNECT payload", h.Auth)
+	}
+	if cookie, err := h.Cookie("session"); err != nil || cookie.Value != "abc" {
+		t.Fatalf("Cookie(session) = %v, %v", cookie, err)
+	}
+	if _, err := h.Cookie("missing"); !errors.Is(err, http.ErrNoCookie) {
+		t.Fatalf("Cookie(missing) = %v, want http.ErrNoCookie", err)
+	}
+	if len(h.Cookies()) != 2 {
+		t.Fatalf("got cookies %v, want 2", h.Cookies())
+	}
+
+	// Every namespace of the connection shares its request, with its own auth
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := alice.Manager().Socket("/chat").Connect(ctx); err != nil {
+		t.Fatalf("Connect(/chat): %v", err)
+	}
+	chat := <-handshakes
+	if chat.Headers.Get("X-Tenant") != "acme" || chat.Auth["token"] != "secret" || chat == h {
+		t.Fatalf("got /chat handshake %+v", chat)
+	}
+}
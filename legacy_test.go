@@ -0,0 +1,155 @@
+package gosocketio_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+// rawClient is a WebSocket connection speaking Engine.IO packets directly,
+// for protocol revisions the client package does not implement
+type rawClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// dialRaw opens a WebSocket connection with the Engine.IO revision and
+// returns it with the session ID of its handshake
+func dialRaw(t *testing.T, server *siotest.Server, eio string) (*rawClient, string) {
+	t.Helper()
+
+	conn, _, err := server.Options().Dialer.Dial("ws://siotest/socket.io/?transport=websocket&EIO="+eio, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	c := &rawClient{t: t, conn: conn}
+	var handshake struct {
+		SID string `json:"sid"`
+	}
+	open := c.read()
+	if !strings.HasPrefix(open, "0") || json.Unmarshal([]byte(open[1:]), &handshake) != nil {
+		t.Fatalf("got %q, want an OPEN packet", open)
+	}
+	return c, handshake.SID
+}
+
+func (c *rawClient) read() string {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	return string(data)
+}
+
+func (c *rawClient) send(data string) {
+	c.t.Helper()
+
+	if err := c.conn.WriteMessage(websocket.TextMessage, []byte(data)); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+func TestLegacyClient(t *testing.T) {
+	server := siotest.NewServer(t, &gosocketio.Config{
+		AllowEIO3:               true,
+		ConnectionStateRecovery: &gosocketio.RecoveryConfig{},
+	})
+
+	sockets := make(chan *gosocketio.Socket, 4)
+	server.OnConnect(func(socket *gosocketio.Socket) { sockets <- socket })
+	chat := server.Of("/chat")
+	chat.Use(func(socket *gosocketio.Socket, next func(error)) {
+		if socket.Handshake().Auth["token"] == "bad" {
+			next(&gosocketio.ConnectError{Message: "forbidden"})
+			return
+		}
+		next(nil)
+	})
+	chat.OnConnect(func(socket *gosocketio.Socket) { sockets <- socket })
+
+	legacy, sid := dialRaw(t, server, "3")
+
+	// The main namespace is connected without a CONNECT packet, and the
+	// CONNECT packet sent back has no payload
+	if connect := legacy.read(); connect != "40" {
+		t.Fatalf("got %q, want an empty CONNECT packet", connect)
+	}
+	if socket := <-sockets; socket.ID() != sid {
+		t.Fatalf("socket ID = %q, want the session ID %q", socket.ID(), sid)
+	}
+
+	// The query of the namespace is the auth, and errors carry only a string
+	legacy.send("40/chat?token=bad")
+	if refused := legacy.read(); refused != `44/chat,"forbidden"` {
+		t.Fatalf("got %q, want a string ERROR packet", refused)
+	}
+
+	legacy.send("40/chat?token=good")
+	if connect := legacy.read(); connect != "40/chat," {
+		t.Fatalf("got %q, want an empty CONNECT packet", connect)
+	}
+	socket := <-sockets
+	if socket.ID() != "/chat#"+sid {
+		t.Fatalf("socket ID = %q, want %q", socket.ID(), "/chat#"+sid)
+	}
+	if token := socket.Handshake().Auth["token"]; token != "good" {
+		t.Fatalf("auth token = %v, want the query parameter", token)
+	}
+}
+
+func TestLegacyClientSkipsRecovery(t *testing.T) {
+	server := siotest.NewServer(t, &gosocketio.Config{
+		AllowEIO3:               true,
+		ConnectionStateRecovery: &gosocketio.RecoveryConfig{},
+	})
+
+	sockets := make(chan *gosocketio.Socket, 2)
+	server.Of("/chat").OnConnect(func(socket *gosocketio.Socket) { sockets <- socket })
+
+	// A v4 client gets a private session ID, then loses its connection
+	modern, _ := dialRaw(t, server, "4")
+	modern.send("40/chat,")
+	var session struct {
+		SID string `json:"sid"`
+		PID string `json:"pid"`
+	}
+	connect := modern.read()
+	if !strings.HasPrefix(connect, "40/chat,") || json.Unmarshal([]byte(strings.TrimPrefix(connect, "40/chat,")), &session) != nil || session.PID == "" {
+		t.Fatalf("got %q, want a CONNECT packet with a private session ID", connect)
+	}
+	lost := <-sockets
+	lost.Join("room")
+	disconnected := make(chan string, 1)
+	lost.OnDisconnect(func(reason string) { disconnected <- reason })
+	modern.conn.Close()
+	<-disconnected
+
+	// A v2 client presenting it in its query is not recovered
+	legacy, sid := dialRaw(t, server, "3")
+	if connect := legacy.read(); connect != "40" {
+		t.Fatalf("got %q, want an empty CONNECT packet", connect)
+	}
+	legacy.send("40/chat?pid=" + session.PID + "&offset=")
+	if connect := legacy.read(); connect != "40/chat," {
+		t.Fatalf("got %q, want an empty CONNECT packet", connect)
+	}
+
+	socket := <-sockets
+	if socket.Recovered() || socket.ID() == session.SID || socket.ID() != "/chat#"+sid {
+		t.Fatalf("socket %q recovered %v, want a new socket", socket.ID(), socket.Recovered())
+	}
+	if rooms := socket.Rooms(); len(rooms) != 1 {
+		t.Fatalf("socket rooms = %v, want only its own room", rooms)
+	}
+}
@@ -1,9 +1,10 @@
 package gosocketio
 
 import (
+	"context"
+	"strconv"
 	"sync"
-
-	"github.com/ramory-l/gosocketio/engineio"
+	"time"
 )
 
 // MemoryAdapter is an in-memory implementation of the Adapter interface
@@ -12,6 +13,21 @@ type MemoryAdapter struct {
 	socketRooms map[string]map[string]bool // socketID -> rooms
 	mu          sync.RWMutex
 	namespace   *Namespace
+
+	// Connection state recovery
+	sessions map[string]*SessionState // pid -> session
+	packets  []bufferedPacket
+	epoch    string
+	offset   uint64
+}
+
+// bufferedPacket is a broadcast packet kept for connection state recovery
+type bufferedPacket struct {
+	offset string
+	packet *Packet
+	rooms  []string
+	except []string
+	time   time.Time
 }
 
 // NewMemoryAdapter creates a new in-memory adapter
@@ -20,6 +36,8 @@ func NewMemoryAdapter(namespace *Namespace) *MemoryAdapter {
 		rooms:       make(map[string]map[string]bool),
 		socketRooms: make(map[string]map[string]bool),
 		namespace:   namespace,
+		sessions:    make(map[string]*SessionState),
+		epoch:       generateID(),
 	}
 }
 
@@ -103,8 +121,116 @@ func (a *MemoryAdapter) SocketRooms(socketID string) []string {
 	return result
 }
 
-// Broadcast sends a packet to all sockets in specified rooms except excluded ones
-func (a *MemoryAdapter) Broadcast(packet *Packet, rooms []string, except []string) error {
+// Broadcast sends a packet to all sockets in specified rooms except excluded
+// ones, and returns a *BroadcastError listing the sockets it could not be
+// sent to
+func (a *MemoryAdapter) Broadcast(ctx context.Context, packet *Packet, rooms []string, except []string) error {
+	if recovery := a.recovery(); recovery != nil && packet.Type == PacketTypeEvent && packet.ID == nil {
+		packet = a.bufferPacket(packet, rooms, except, recovery)
+	}
+
+	packets, err := encodePacket(a.namespace.server.parser(), packet)
+	if err != nil {
+		return err
+	}
+
+	// Sockets whose queue is full are handled by Config.OverflowPolicy and
+	// reported to Config.OnOverflow. With OverflowBlock, the broadcast waits
+	// for each of them in turn until ctx is done, which keeps broadcasts in
+	// order.
+	var failed map[string]error
+	for _, socket := range a.targets(rooms, except) {
+		if err := socket.session.SendContext(ctx, packets...); err != nil {
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[socket.ID()] = err
+		}
+	}
+
+	if failed != nil {
+		return &BroadcastError{Errors: failed}
+	}
+	return nil
+}
+
+// BroadcastWithAck sends a packet to all targeted sockets, each with its own
+// ack ID, and reports their acknowledgments through onAck
+func (a *MemoryAdapter) BroadcastWithAck(ctx context.Context, packet *Packet, rooms []string, except []string,
+	onTargets func(socketIDs []string), onAck func(socketID string, args []interface{}, err error)) error {
+	sockets := a.targets(rooms, except)
+
+	ids := make([]string, len(sockets))
+	for i, socket := range sockets {
+		ids[i] = socket.ID()
+	}
+	onTargets(ids)
+
+	pending := make(map[*Socket]int, len(sockets))
+	for _, socket := range sockets {
+		socket := socket
+		id, err := socket.sendWithAck(ctx, packet, func(args []interface{}, err error) {
+			onAck(socket.ID(), args, err)
+		})
+		if err != nil {
+			onAck(socket.ID(), nil, err)
+			continue
+		}
+		pending[socket] = id
+	}
+
+	// Drop acknowledgments that are no longer awaited
+	go func() {
+		<-ctx.Done()
+		for socket, id := range pending {
+			socket.ackHandlers.Delete(id)
+		}
+	}()
+
+	return nil
+}
+
+// ServerCount returns 1, as the memory adapter only knows the local server
+func (a *MemoryAdapter) ServerCount() int {
+	return 1
+}
+
+// FetchSockets returns the matching sockets of the local server
+func (a *MemoryAdapter) FetchSockets(ctx context.Context, rooms []string, except []string) ([]*RemoteSocket, error) {
+	return remoteSockets(a.targets(rooms, except)), nil
+}
+
+// AddSockets makes the matching sockets join the rooms in join
+func (a *MemoryAdapter) AddSockets(rooms []string, except []string, join []string) error {
+	joinSockets(a.targets(rooms, except), join)
+	return nil
+}
+
+// DelSockets makes the matching sockets leave the rooms in leave
+func (a *MemoryAdapter) DelSockets(rooms []string, except []string, leave []string) error {
+	leaveSockets(a.targets(rooms, except), leave)
+	return nil
+}
+
+// DisconnectSockets disconnects the matching sockets
+func (a *MemoryAdapter) DisconnectSockets(rooms []string, except []string, close bool) error {
+	disconnectSockets(a.targets(rooms, except), close)
+	return nil
+}
+
+// ServerSideEmit does nothing, as there is no other server to reach
+func (a *MemoryAdapter) ServerSideEmit(packet *Packet) error {
+	return nil
+}
+
+// ServerSideEmitWithAck does nothing, as there is no other server to reach
+func (a *MemoryAdapter) ServerSideEmitWithAck(ctx context.Context, packet *Packet, onResponse func(args []interface{})) error {
+	return nil
+}
+
+// targets returns the connected sockets in the given rooms, or in the whole
+// namespace if rooms is empty, except the excluded socket IDs
+func (a *MemoryAdapter) targets(rooms []string, except []string) []*Socket {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
@@ -114,49 +240,190 @@ func (a *MemoryAdapter) Broadcast(packet *Packet, rooms []string, except []strin
 		excludeMap[sid] = true
 	}
 
-	// Collect target socket IDs
-	targets := make(map[string]bool)
+	a.namespace.mu.RLock()
+	defer a.namespace.mu.RUnlock()
+
+	var sockets []*Socket
 
 	if len(rooms) == 0 {
 		// Broadcast to all sockets in namespace
-		a.namespace.mu.RLock()
-		for sid := range a.namespace.sockets {
+		for sid, socket := range a.namespace.sockets {
 			if !excludeMap[sid] {
-				targets[sid] = true
+				sockets = append(sockets, socket)
+			}
+		}
+		return sockets
+	}
+
+	// Broadcast to specific rooms
+	seen := make(map[string]bool)
+	for _, room := range rooms {
+		for socketID := range a.rooms[room] {
+			if excludeMap[socketID] || seen[socketID] {
+				continue
+			}
+			seen[socketID] = true
+			if socket, ok := a.namespace.sockets[socketID]; ok {
+				sockets = append(sockets, socket)
+			}
+		}
+	}
+	return sockets
+}
+
+// PersistSession stores the state of a disconnected socket for recovery
+func (a *MemoryAdapter) PersistSession(session *SessionState) {
+	recovery := a.recovery()
+	if recovery == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if session.DisconnectedAt.IsZero() {
+		session.DisconnectedAt = a.namespace.server.clock().Now()
+	}
+	a.sessions[session.PID] = session
+	a.pruneSessions(recovery)
+}
+
+// RestoreSession returns a stored session along with the packets broadcast to
+// its rooms after offset
+func (a *MemoryAdapter) RestoreSession(pid, offset string) (*SessionState, bool) {
+	recovery := a.recovery()
+	if recovery == nil {
+		return nil, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pruneSessions(recovery)
+	a.prunePackets(recovery)
+
+	session, ok := a.sessions[pid]
+	if !ok {
+		return nil, false
+	}
+	delete(a.sessions, pid)
+
+	// Find the first packet the client has not received
+	start := -1
+	if offset == "" {
+		start = len(a.packets)
+		for i, buffered := range a.packets {
+			if buffered.time.After(session.DisconnectedAt) {
+				start = i
+				break
 			}
 		}
-		a.namespace.mu.RUnlock()
 	} else {
-		// Broadcast to specific rooms
-		for _, room := range rooms {
-			for socketID := range a.rooms[room] {
-				if !excludeMap[socketID] {
-					targets[socketID] = true
-				}
+		for i, buffered := range a.packets {
+			if buffered.offset == offset {
+				start = i + 1
+				break
 			}
 		}
 	}
+	if start == -1 {
+		// The offset is no longer buffered, so packets may have been lost
+		return nil, false
+	}
 
-	// Send to target sockets
-	encoded, err := packet.Encode()
-	if err != nil {
-		return err
+	joined := make(map[string]bool, len(session.Rooms))
+	for _, room := range session.Rooms {
+		joined[room] = true
 	}
 
-	a.namespace.mu.RLock()
-	defer a.namespace.mu.RUnlock()
+	restored := *session
+	restored.MissedPackets = nil
+	for _, buffered := range a.packets[start:] {
+		if buffered.targets(session.SID, joined) {
+			restored.MissedPackets = append(restored.MissedPackets, buffered.packet)
+		}
+	}
 
-	for socketID := range targets {
-		if socket, ok := a.namespace.sockets[socketID]; ok {
-			// Non-blocking send
-			go socket.session.Send(&engineio.Packet{
-				Type: engineio.PacketTypeMessage,
-				Data: []byte(encoded),
-			})
+	return &restored, true
+}
+
+// recovery returns the connection state recovery configuration, or nil if
+// recovery is disabled
+func (a *MemoryAdapter) recovery() *RecoveryConfig {
+	if a.namespace == nil || a.namespace.server == nil {
+		return nil
+	}
+	return a.namespace.server.config.ConnectionStateRecovery
+}
+
+// bufferPacket returns a copy of the packet with an offset appended to its
+// data and keeps it for replay
+func (a *MemoryAdapter) bufferPacket(packet *Packet, rooms []string, except []string, recovery *RecoveryConfig) *Packet {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.offset++
+	offset := a.epoch + "-" + strconv.FormatUint(a.offset, 10)
+
+	args := packet.decodedArgs()
+	withOffset := *packet
+	withOffset.Data = append(args[:len(args):len(args)], offset)
+
+	a.packets = append(a.packets, bufferedPacket{
+		offset: offset,
+		packet: &withOffset,
+		rooms:  rooms,
+		except: except,
+		time:   a.namespace.server.clock().Now(),
+	})
+	a.prunePackets(recovery)
+
+	return &withOffset
+}
+
+// pruneSessions drops expired sessions. The caller must hold a.mu.
+func (a *MemoryAdapter) pruneSessions(recovery *RecoveryConfig) {
+	cutoff := a.namespace.server.clock().Now().Add(-recovery.maxDisconnectionDuration())
+
+	for pid, session := range a.sessions {
+		if session.DisconnectedAt.Before(cutoff) {
+			delete(a.sessions, pid)
 		}
 	}
+}
 
-	return nil
+// prunePackets drops packets beyond the buffer size, then packets older than
+// the recovery window. The caller must hold a.mu.
+func (a *MemoryAdapter) prunePackets(recovery *RecoveryConfig) {
+	cutoff := a.namespace.server.clock().Now().Add(-recovery.maxDisconnectionDuration())
+
+	drop := max(len(a.packets)-recovery.maxBufferedPackets(), 0)
+	for drop < len(a.packets) && a.packets[drop].time.Before(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		a.packets = append([]bufferedPacket(nil), a.packets[drop:]...)
+	}
+}
+
+// targets reports whether a socket with the given ID and rooms was a
+// recipient of the packet
+func (p *bufferedPacket) targets(sid string, rooms map[string]bool) bool {
+	for _, id := range p.except {
+		if id == sid {
+			return false
+		}
+	}
+
+	if len(p.rooms) == 0 {
+		return true
+	}
+	for _, room := range p.rooms {
+		if rooms[room] {
+			return true
+		}
+	}
+	return false
 }
 
 // Close cleans up the adapter
@@ -166,6 +433,8 @@ func (a *MemoryAdapter) Close() error {
 
 	a.rooms = make(map[string]map[string]bool)
 	a.socketRooms = make(map[string]map[string]bool)
+	a.sessions = make(map[string]*SessionState)
+	a.packets = nil
 
 	return nil
 }
@@ -0,0 +1,101 @@
+package gosocketio_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+func newGuardedServer(t *testing.T) *siotest.Server {
+	server := siotest.NewServer(t, nil)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.Use(func(event string, args []interface{}, next func(error)) {
+			if event == "forbidden" {
+				next(errors.New("not allowed"))
+				return
+			}
+			next(nil)
+		})
+		socket.On("forbidden", func(args ...interface{}) {
+			t.Error("rejected event reached its handler")
+		})
+	})
+	return server
+}
+
+func TestMiddlewareRejectionAck(t *testing.T) {
+	server := newGuardedServer(t)
+	alice := server.Connect(t, "/")
+
+	response := alice.ExpectAck(t, time.Second, "forbidden", "data")
+	result, _ := response[0].(map[string]interface{})
+	if result["error"] != "not allowed" {
+		t.Fatalf("got acknowledgment %v, want the error", response)
+	}
+	alice.ExpectNoEvent(t, "error", 50*time.Millisecond)
+}
+
+func TestMiddlewareRejectionEvent(t *testing.T) {
+	server := newGuardedServer(t)
+	alice := server.Connect(t, "/")
+
+	if err := alice.Emit("forbidden", "data"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+
+	event := alice.ExpectEvent(t, "error", time.Second)
+	result, _ := event.Args[0].(map[string]interface{})
+	if result["event"] != "forbidden" || result["message"] != "not allowed" {
+		t.Fatalf("got error event %v", event.Args)
+	}
+}
+
+func TestMiddlewarePanicHidden(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+
+	reported := make(chan error, 2)
+	server.OnError(func(err error, socket *gosocketio.Socket, event string) {
+		reported <- err
+	})
+	local := make(chan error, 2)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.Use(func(event string, args []interface{}, next func(error)) {
+			panic("db password=hunter2")
+		})
+		socket.On("error", func(args ...interface{}) {
+			err, _ := args[0].(error)
+			local <- err
+		})
+	})
+	alice := server.Connect(t, "/")
+
+	response := alice.ExpectAck(t, time.Second, "query", "data")
+	result, _ := response[0].(map[string]interface{})
+	if result["error"] != "internal server error" {
+		t.Fatalf("got acknowledgment %v, want internal server error", response)
+	}
+
+	if err := alice.Emit("query", "data"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	event := alice.ExpectEvent(t, "error", time.Second)
+	result, _ = event.Args[0].(map[string]interface{})
+	if result["message"] != "internal server error" {
+		t.Fatalf("got error event %v, want internal server error", event.Args)
+	}
+
+	for _, errs := range []chan error{reported, local} {
+		select {
+		case err := <-errs:
+			var panicErr *gosocketio.PanicError
+			if !errors.As(err, &panicErr) || panicErr.Value != "db password=hunter2" || len(panicErr.Stack) == 0 {
+				t.Fatalf("got error %#v, want the *PanicError with its stack", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("panic not reported")
+		}
+	}
+}
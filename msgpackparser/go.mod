@@ -0,0 +1,16 @@
+module github.com/ramory-l/gosocketio/msgpackparser
+
+go 1.26
+
+require (
+	github.com/ramory-l/gosocketio v0.0.0-00010101000000-000000000000
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+)
+
+require (
+	github.com/gorilla/websocket v1.5.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+)
+
+replace github.com/ramory-l/gosocketio => ../
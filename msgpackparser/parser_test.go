@@ -0,0 +1,212 @@
+package msgpackparser
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ramory-l/gosocketio"
+)
+
+// fixture decodes a hex dump of a frame, ignoring spaces
+func fixture(t *testing.T, dump string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(strings.ReplaceAll(dump, " ", ""))
+	if err != nil {
+		t.Fatalf("invalid fixture %q: %v", dump, err)
+	}
+	return data
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// The fixtures are the bytes notepack.io produces for the same objects, with
+// the keys in the order of the packet struct.
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet *gosocketio.Packet
+		want   string
+	}{
+		{
+			// {type: 2, nsp: "/", data: ["hello", "world"]}
+			name: "event",
+			packet: &gosocketio.Packet{
+				Type: gosocketio.PacketTypeEvent,
+				Data: []interface{}{"hello", "world"},
+			},
+			want: "83 a474797065 02 a36e7370 a12f a464617461 92 a568656c6c6f a5776f726c64",
+		},
+		{
+			// {type: 3, nsp: "/", data: ["ok", 1.5], id: 7}
+			name: "ack",
+			packet: &gosocketio.Packet{
+				Type:      gosocketio.PacketTypeAck,
+				Namespace: "/",
+				Data:      []interface{}{"ok", 1.5},
+				ID:        intPtr(7),
+			},
+			want: "84 a474797065 03 a36e7370 a12f a464617461 92 a26f6b cb3ff8000000000000 a26964 07",
+		},
+		{
+			// {type: 2, nsp: "/", data: ["file", <Buffer 01 02 03>]}
+			name: "binary event",
+			packet: &gosocketio.Packet{
+				Type:        gosocketio.PacketTypeBinaryEvent,
+				Namespace:   "/",
+				Data:        []interface{}{"file", []byte{1, 2, 3}},
+				Attachments: 1,
+			},
+			want: "83 a474797065 02 a36e7370 a12f a464617461 92 a466696c65 c403010203",
+		},
+		{
+			// {type: 2, nsp: "/admin", data: ["ping", 1]}
+			name: "namespace",
+			packet: &gosocketio.Packet{
+				Type:      gosocketio.PacketTypeEvent,
+				Namespace: "/admin",
+				Data:      []interface{}{"ping", 1},
+			},
+			want: "83 a474797065 02 a36e7370 a62f61646d696e a464617461 92 a470696e67 01",
+		},
+		{
+			// {type: 0, nsp: "/", data: {sid: "abc"}}
+			name: "connect",
+			packet: &gosocketio.Packet{
+				Type:      gosocketio.PacketTypeConnect,
+				Namespace: "/",
+				Data:      map[string]interface{}{"sid": "abc"},
+			},
+			want: "83 a474797065 00 a36e7370 a12f a464617461 81 a3736964 a3616263",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := Parser{}.Encode(tt.packet)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if len(frames) != 1 || !frames[0].Binary {
+				t.Fatalf("got %d frames, want a single binary frame", len(frames))
+			}
+			if want := fixture(t, tt.want); !bytes.Equal(frames[0].Data, want) {
+				t.Fatalf("got % x, want % x", frames[0].Data, want)
+			}
+		})
+	}
+}
+
+// The fixtures are frames sent by socket.io-client with the msgpack parser:
+// the keys are in the order the client sets them, including the options it
+// does not strip.
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want *gosocketio.Packet
+	}{
+		{
+			// {type: 2, data: ["hello", "world"], options: {compress: true}, nsp: "/"}
+			name: "event",
+			data: "84 a474797065 02 a464617461 92 a568656c6c6f a5776f726c64 a76f7074696f6e73 81 a8636f6d7072657373 c3 a36e7370 a12f",
+			want: &gosocketio.Packet{
+				Type:      gosocketio.PacketTypeEvent,
+				Namespace: "/",
+				Data:      []interface{}{"hello", "world"},
+			},
+		},
+		{
+			// {type: 3, nsp: "/", id: 12, data: [{count: 2}]}
+			name: "ack",
+			data: "84 a474797065 03 a36e7370 a12f a26964 0c a464617461 91 81 a5636f756e74 02",
+			want: &gosocketio.Packet{
+				Type:      gosocketio.PacketTypeAck,
+				Namespace: "/",
+				Data:      []interface{}{map[string]interface{}{"count": float64(2)}},
+				ID:        intPtr(12),
+			},
+		},
+		{
+			// {type: 2, data: ["upload", <Buffer 01 02>, -1], id: 3, nsp: "/"}
+			name: "binary event with ack",
+			data: "84 a474797065 02 a464617461 93 a675706c6f6164 c4020102 ff a26964 03 a36e7370 a12f",
+			want: &gosocketio.Packet{
+				Type:      gosocketio.PacketTypeEvent,
+				Namespace: "/",
+				Data:      []interface{}{"upload", []byte{1, 2}, float64(-1)},
+				ID:        intPtr(3),
+			},
+		},
+		{
+			// {type: 2, data: ["ping", 0.5], nsp: "/admin"}
+			name: "namespace",
+			data: "83 a474797065 02 a464617461 92 a470696e67 cb3fe0000000000000 a36e7370 a62f61646d696e",
+			want: &gosocketio.Packet{
+				Type:      gosocketio.PacketTypeEvent,
+				Namespace: "/admin",
+				Data:      []interface{}{"ping", 0.5},
+			},
+		},
+		{
+			// {type: 0, data: {token: "abc"}, nsp: "/admin"}
+			name: "connect with auth",
+			data: "83 a474797065 00 a464617461 81 a5746f6b656e a3616263 a36e7370 a62f61646d696e",
+			want: &gosocketio.Packet{
+				Type:      gosocketio.PacketTypeConnect,
+				Namespace: "/admin",
+				Data:      map[string]interface{}{"token": "abc"},
+			},
+		},
+		{
+			// {type: 0, nsp: "/"}
+			name: "connect without auth",
+			data: "82 a474797065 00 a36e7370 a12f",
+			want: &gosocketio.Packet{
+				Type:      gosocketio.PacketTypeConnect,
+				Namespace: "/",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := Parser{}.NewDecoder().Add(gosocketio.Frame{Data: fixture(t, tt.data), Binary: true})
+			if err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			if !reflect.DeepEqual(packet, tt.want) {
+				t.Fatalf("got %+v, want %+v", packet, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame gosocketio.Frame
+	}{
+		{"text frame", gosocketio.Frame{Data: []byte(`2["hello"]`)}},
+		{"truncated", gosocketio.Frame{Data: fixture(t, "83 a474797065 02 a36e"), Binary: true}},
+		{"not a map", gosocketio.Frame{Data: fixture(t, "92 02 a12f"), Binary: true}},
+		// {type: 7, nsp: "/"}
+		{"unknown type", gosocketio.Frame{Data: fixture(t, "82 a474797065 07 a36e7370 a12f"), Binary: true}},
+		// {type: 2, nsp: 1}
+		{"invalid namespace", gosocketio.Frame{Data: fixture(t, "82 a474797065 02 a36e7370 01"), Binary: true}},
+		// {type: 3, nsp: "/", id: 1.5}
+		{"invalid ID", gosocketio.Frame{Data: fixture(t, "83 a474797065 03 a36e7370 a12f a26964 cb3ff8000000000000"), Binary: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if packet, err := (Parser{}).NewDecoder().Add(tt.frame); err == nil {
+				t.Fatalf("Add accepted the frame: %+v", packet)
+			}
+		})
+	}
+}
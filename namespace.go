@@ -1,11 +1,20 @@
 package gosocketio
 
 import (
+	"context"
+	"errors"
 	"sync"
-
-	"github.com/ramory-l/gosocketio/engineio"
+	"time"
 )
 
+// ErrAckNotSupported is returned by BroadcastOperator.EmitWithAck when the
+// namespace adapter does not implement AckAdapter.
+var ErrAckNotSupported = errors.New("adapter does not support broadcast acknowledgments")
+
+// errInternal is sent to clients whose connection was rejected because a
+// middleware panicked
+var errInternal = errors.New("internal server error")
+
 // Namespace represents a Socket.IO namespace.
 //
 // Namespaces provide logical separation within a single Socket.IO server.
@@ -17,12 +26,51 @@ import (
 //   - Multi-tenancy (e.g., /tenant1, /tenant2)
 //   - Different authorization levels
 type Namespace struct {
-	name      string
-	server    *Server
-	adapter   Adapter
-	sockets   map[string]*Socket
-	mu        sync.RWMutex
-	onConnect func(*Socket)
+	name        string
+	server      *Server
+	adapter     Adapter
+	sockets     map[string]*Socket
+	mu          sync.RWMutex
+	onConnect   func(*Socket)
+	middlewares []MiddlewareFunc
+	parent      *ParentNamespace
+
+	// pending is the number of connections whose middlewares are running,
+	// which keep a child namespace from being removed
+	pending int
+
+	serverSideHandlers   map[string][]EventHandler
+	serverSideHandlersMu sync.RWMutex
+}
+
+// MiddlewareFunc is a function that runs for every incoming connection to a
+// namespace before the socket is admitted.
+//
+// The middleware must call next exactly once. Calling next with a nil error
+// passes control to the following middleware; calling it with a non-nil error
+// rejects the connection with a CONNECT_ERROR packet.
+type MiddlewareFunc func(socket *Socket, next func(error))
+
+// ConnectError is an error that can be returned from a middleware to reject
+// a connection with additional data for the client.
+//
+// The client receives it as an error whose message is Message and whose data
+// property is Data.
+//
+// Example:
+//
+//	next(&gosocketio.ConnectError{
+//	    Message: "unauthorized",
+//	    Data:    map[string]interface{}{"retryAfter": 30},
+//	})
+type ConnectError struct {
+	Message string
+	Data    interface{}
+}
+
+// Error implements the error interface.
+func (e *ConnectError) Error() string {
+	return e.Message
 }
 
 // NewNamespace creates a new namespace
@@ -31,9 +79,15 @@ func NewNamespace(name string, server *Server) *Namespace {
 		name:    name,
 		server:  server,
 		sockets: make(map[string]*Socket),
+
+		serverSideHandlers: make(map[string][]EventHandler),
 	}
 
-	ns.adapter = NewMemoryAdapter(ns)
+	if server != nil && server.config.AdapterFactory != nil {
+		ns.adapter = server.config.AdapterFactory(ns)
+	} else {
+		ns.adapter = NewMemoryAdapter(ns)
+	}
 
 	return ns
 }
@@ -57,6 +111,30 @@ func (ns *Namespace) OnConnect(handler func(*Socket)) {
 	ns.onConnect = handler
 }
 
+// Use registers a middleware that runs for every incoming connection.
+//
+// Middlewares run in the order they were registered, before the socket joins
+// the namespace and before OnConnect is called. The auth payload sent by the
+// client is available through socket.Auth(). If any middleware calls next
+// with an error, the client receives a CONNECT_ERROR packet and the socket is
+// never admitted.
+//
+// Example:
+//
+//	ns.Use(func(socket *gosocketio.Socket, next func(error)) {
+//	    token, _ := socket.Auth()["token"].(string)
+//	    if !isValid(token) {
+//	        next(errors.New("invalid token"))
+//	        return
+//	    }
+//	    next(nil)
+//	})
+func (ns *Namespace) Use(fn MiddlewareFunc) {
+	ns.mu.Lock()
+	ns.middlewares = append(ns.middlewares, fn)
+	ns.mu.Unlock()
+}
+
 // To returns a BroadcastOperator for broadcasting to specific rooms in this namespace.
 //
 // Example:
@@ -69,6 +147,17 @@ func (ns *Namespace) To(rooms ...string) *BroadcastOperator {
 	}
 }
 
+// Timeout returns a BroadcastOperator whose acknowledgments expire after d.
+//
+// Example:
+//
+//	ns.Timeout(5*time.Second).EmitWithAck("ping", func(err error, result *gosocketio.BroadcastAckResult) {
+//	    log.Printf("%d answered, %d missed", len(result.Responses), len(result.Missing))
+//	})
+func (ns *Namespace) Timeout(d time.Duration) *BroadcastOperator {
+	return ns.To().Timeout(d)
+}
+
 // Emit broadcasts an event to all connected sockets in this namespace.
 //
 // Example:
@@ -78,6 +167,28 @@ func (ns *Namespace) Emit(event string, data ...interface{}) error {
 	return ns.To().Emit(event, data...)
 }
 
+// FetchSockets returns the sockets of this namespace, wherever they are connected.
+//
+// This is a convenience method equivalent to calling ns.To().FetchSockets(ctx).
+func (ns *Namespace) FetchSockets(ctx context.Context) ([]*RemoteSocket, error) {
+	return ns.To().FetchSockets(ctx)
+}
+
+// SocketsJoin makes every socket of this namespace join the rooms.
+func (ns *Namespace) SocketsJoin(rooms ...string) error {
+	return ns.To().SocketsJoin(rooms...)
+}
+
+// SocketsLeave makes every socket of this namespace leave the rooms.
+func (ns *Namespace) SocketsLeave(rooms ...string) error {
+	return ns.To().SocketsLeave(rooms...)
+}
+
+// DisconnectSockets disconnects every socket of this namespace.
+func (ns *Namespace) DisconnectSockets(close bool) error {
+	return ns.To().DisconnectSockets(close)
+}
+
 // Sockets returns all currently connected sockets in this namespace.
 //
 // Example:
@@ -126,35 +237,207 @@ func (ns *Namespace) SetAdapter(adapter Adapter) {
 	ns.adapter = adapter
 }
 
-func (ns *Namespace) addSocket(session *engineio.Session) {
-	socket := NewSocket(session.ID(), session, ns)
+func (ns *Namespace) addSocket(c *client, auth map[string]interface{}) {
+	id := generateID()
+	if c.legacy() {
+		// Socket.IO v2 socket IDs are derived from the Engine.IO session ID
+		id = c.session.ID()
+		if ns.name != "/" {
+			id = ns.name + "#" + id
+		}
+	}
+
+	socket := NewSocket(id, c.session, ns)
+	socket.client = c
+	socket.handshake.Auth = auth
+
+	var session *SessionState
+	if recovery := ns.server.config.ConnectionStateRecovery; recovery != nil && !c.legacy() {
+		session = ns.restoreSession(auth)
+		if session != nil {
+			socket.id = session.SID
+			socket.pid = session.PID
+			socket.recovered = true
+			for key, value := range session.Data {
+				socket.data.Store(key, value)
+			}
+		} else {
+			socket.pid = generateID()
+		}
+
+		if session != nil && recovery.SkipMiddlewares {
+			ns.connect(socket, session)
+			return
+		}
+	}
+
+	ns.runMiddlewares(socket, func(err error) {
+		if err != nil {
+			ns.admit(nil)
+			c.rejectSocket(ns.name, err)
+			ns.cleanup()
+			return
+		}
+
+		ns.connect(socket, session)
+	})
+}
+
+// restoreSession looks up the session of a reconnecting client from the
+// private session ID and offset in its auth payload
+func (ns *Namespace) restoreSession(auth map[string]interface{}) *SessionState {
+	pid, _ := auth["pid"].(string)
+	if pid == "" {
+		return nil
+	}
 
-	ns.mu.Lock()
-	ns.sockets[socket.ID()] = socket
-	ns.mu.Unlock()
+	adapter, ok := ns.adapter.(SessionAwareAdapter)
+	if !ok {
+		return nil
+	}
 
-	// Auto-join own room
-	socket.Join(socket.ID())
+	offset, _ := auth["offset"].(string)
+	session, ok := adapter.RestoreSession(pid, offset)
+	if !ok {
+		return nil
+	}
+	return session
+}
+
+func (ns *Namespace) connect(socket *Socket, session *SessionState) {
+	if !ns.admit(socket) {
+		socket.client.rejectSocket(ns.name, errInvalidNamespace)
+		return
+	}
+	if !socket.client.addSocket(socket) {
+		// The connection closed while middlewares were running
+		ns.removeSocket(socket.ID())
+		return
+	}
 
-	// Send connect packet
+	// Send connect packet. Socket.IO v2 clients expect no payload.
 	connectPacket := &Packet{
 		Type:      PacketTypeConnect,
 		Namespace: ns.name,
-		Data:      map[string]interface{}{"sid": socket.ID()},
+	}
+	if !socket.client.legacy() {
+		data := map[string]interface{}{"sid": socket.ID()}
+		if socket.pid != "" {
+			data["pid"] = socket.pid
+		}
+		connectPacket.Data = data
 	}
 	socket.sendPacket(connectPacket)
 
+	// Replay missed broadcasts before joining any room, so that they reach
+	// the client before the broadcasts sent from now on
+	if session != nil {
+		for _, packet := range session.MissedPackets {
+			socket.sendPacket(packet)
+		}
+	}
+
+	// Auto-join own room, and restore rooms
+	socket.Join(socket.ID())
+	if session != nil {
+		for _, room := range session.Rooms {
+			socket.Join(room)
+		}
+	}
+
 	if ns.onConnect != nil {
-		ns.onConnect(socket)
+		socket.safeCall("connect", func() { ns.onConnect(socket) })
+	}
+	if ns.parent != nil {
+		if onConnect, _ := ns.parent.handlers(); onConnect != nil {
+			socket.safeCall("connect", func() { onConnect(socket) })
+		}
 	}
 }
 
+func (ns *Namespace) runMiddlewares(socket *Socket, done func(error)) {
+	var middlewares []MiddlewareFunc
+	if ns.parent != nil {
+		_, middlewares = ns.parent.handlers()
+	}
+
+	ns.mu.RLock()
+	middlewares = append(middlewares, ns.middlewares...)
+	ns.mu.RUnlock()
+
+	// A panicking middleware rejects the connection, unless it already called next
+	var once sync.Once
+	finish := func(err error) {
+		once.Do(func() { done(err) })
+	}
+
+	var run func(i int)
+	run = func(i int) {
+		if i == len(middlewares) {
+			finish(nil)
+			return
+		}
+
+		defer func() {
+			if value := recover(); value != nil {
+				ns.server.reportPanic(value, socket, "connect")
+				finish(errInternal)
+			}
+		}()
+
+		middlewares[i](socket, func(err error) {
+			if err != nil {
+				finish(err)
+				return
+			}
+			run(i + 1)
+		})
+	}
+
+	run(0)
+}
+
+// reserve counts a pending connection. The caller must hold the lock of the
+// server namespaces, so that the namespace cannot be removed first.
+func (ns *Namespace) reserve() {
+	ns.mu.Lock()
+	ns.pending++
+	ns.mu.Unlock()
+}
+
+// admit ends a pending connection and adds the socket, unless it is nil or
+// the namespace has been removed from the server. It reports whether the
+// socket was added.
+func (ns *Namespace) admit(socket *Socket) bool {
+	ns.server.nsMu.RLock()
+	defer ns.server.nsMu.RUnlock()
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.pending--
+	if socket == nil || ns.server.namespaces[ns.name] != ns {
+		return false
+	}
+	ns.sockets[socket.ID()] = socket
+	return true
+}
+
 func (ns *Namespace) removeSocket(id string) {
 	ns.mu.Lock()
 	delete(ns.sockets, id)
 	ns.mu.Unlock()
 
 	ns.adapter.RemoveAll(id)
+	ns.cleanup()
+}
+
+// cleanup removes the namespace from the server if it is an empty child
+// namespace and Config.CleanupEmptyChildNamespaces is set
+func (ns *Namespace) cleanup() {
+	if ns.parent != nil && ns.server.config.CleanupEmptyChildNamespaces {
+		ns.server.removeChild(ns)
+	}
 }
 
 // BroadcastOperator provides a fluent interface for broadcasting events to specific rooms.
@@ -171,6 +454,19 @@ type BroadcastOperator struct {
 	namespace *Namespace
 	rooms     []string
 	except    []string
+	timeout   time.Duration
+	local     bool
+}
+
+// BroadcastAckResult holds the acknowledgments collected by
+// BroadcastOperator.EmitWithAck.
+type BroadcastAckResult struct {
+	// Responses maps the ID of each socket that answered to its acknowledgment data.
+	Responses map[string][]interface{}
+
+	// Missing lists the IDs of targeted sockets that did not answer in time,
+	// disconnected before answering or could not be sent the event.
+	Missing []string
 }
 
 // To adds additional rooms to broadcast to.
@@ -198,15 +494,154 @@ func (b *BroadcastOperator) Except(socketIDs ...string) *BroadcastOperator {
 	return b
 }
 
+// Local restricts the broadcast to sockets connected to this server.
+//
+// It only makes a difference with adapters spanning several servers, such as
+// a Redis adapter; those must implement LocalBroadcaster.
+//
+// Example:
+//
+//	server.To("room1").Local().Emit("cache_invalidated")
+func (b *BroadcastOperator) Local() *BroadcastOperator {
+	b.local = true
+	return b
+}
+
 // Emit broadcasts an event to all targeted sockets.
 //
 // If no rooms were specified with To(), broadcasts to all sockets in the namespace.
 // Sockets specified in Except() will not receive the event.
 //
+// If some sockets could not be sent the event, a *BroadcastError lists them;
+// the other sockets were sent the event.
+//
 // Example:
 //
 //	server.To("room1").Except(socket.ID()).Emit("message", "Hello room!")
 func (b *BroadcastOperator) Emit(event string, data ...interface{}) error {
+	return b.EmitContext(context.Background(), event, data...)
+}
+
+// EmitContext broadcasts an event like Emit. With engineio.OverflowBlock, it
+// waits for slow sockets until ctx is done; those still waiting then fail
+// with ctx.Err() in the returned *BroadcastError.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//	defer cancel()
+//
+//	err := server.To("room1").EmitContext(ctx, "tick", n)
+//	var broadcastErr *gosocketio.BroadcastError
+//	if errors.As(err, &broadcastErr) {
+//	    log.Printf("%d sockets missed the tick", len(broadcastErr.Errors))
+//	}
+func (b *BroadcastOperator) EmitContext(ctx context.Context, event string, data ...interface{}) error {
+	args := make([]interface{}, 0, len(data)+1)
+	args = append(args, event)
+	args = append(args, data...)
+
+	packet := &Packet{
+		Type:      PacketTypeEvent,
+		Namespace: b.namespace.name,
+		Data:      args,
+	}
+
+	if b.local {
+		if adapter, ok := b.namespace.adapter.(LocalBroadcaster); ok {
+			return adapter.BroadcastLocal(ctx, packet, b.rooms, b.except)
+		}
+	}
+
+	return b.namespace.adapter.Broadcast(ctx, packet, b.rooms, b.except)
+}
+
+// Timeout sets how long EmitWithAck waits for acknowledgments.
+//
+// Example:
+//
+//	server.To("kiosks").Timeout(5*time.Second).EmitWithAckContext(ctx, "config", cfg)
+func (b *BroadcastOperator) Timeout(d time.Duration) *BroadcastOperator {
+	b.timeout = d
+	return b
+}
+
+// EmitWithAck broadcasts an event to all targeted sockets and calls ack once every
+// socket has acknowledged it or failed to, or the timeout set with Timeout
+// expires.
+//
+// The result lists the responses received and the sockets that missed the
+// deadline, in which case err is context.DeadlineExceeded. Sockets that
+// disconnect before answering, or that the event could not be sent to, are
+// listed as missing too; if no deadline was missed, err is then the error of
+// the first of them, such as ErrSocketDisconnected. Without a timeout, ack is
+// called once every targeted socket has answered or failed.
+//
+// Example:
+//
+//	server.Timeout(5*time.Second).EmitWithAck("still_there", func(err error, result *gosocketio.BroadcastAckResult) {
+//	    for _, id := range result.Missing {
+//	        log.Printf("Socket %s did not answer", id)
+//	    }
+//	})
+func (b *BroadcastOperator) EmitWithAck(event string, ack func(err error, result *BroadcastAckResult), data ...interface{}) error {
+	ctx, cancel := b.withTimeout(context.Background())
+
+	collector, err := b.broadcastWithAck(ctx, event, data)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	go func() {
+		defer cancel()
+		defer b.namespace.server.recoverPanic(nil, event)
+		result, err := collector.wait(ctx)
+		ack(err, result)
+	}()
+
+	return nil
+}
+
+// EmitWithAckContext broadcasts an event to all targeted sockets and waits until
+// every socket has acknowledged it, the timeout set with Timeout expires or ctx
+// is done.
+//
+// If some sockets did not answer, the partial result is returned along with
+// the context error, or the error of the first socket that disconnected or
+// could not be sent the event.
+//
+// Example:
+//
+//	result, err := server.To("kiosks").Timeout(5*time.Second).EmitWithAckContext(ctx, "config", cfg)
+//	if err != nil {
+//	    log.Printf("Kiosks that missed the rollout: %v", result.Missing)
+//	}
+func (b *BroadcastOperator) EmitWithAckContext(ctx context.Context, event string, data ...interface{}) (*BroadcastAckResult, error) {
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+
+	collector, err := b.broadcastWithAck(ctx, event, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return collector.wait(ctx)
+}
+
+func (b *BroadcastOperator) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.timeout > 0 {
+		return context.WithTimeout(ctx, b.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (b *BroadcastOperator) broadcastWithAck(ctx context.Context, event string, data []interface{}) (*ackCollector, error) {
+	adapter, ok := b.namespace.adapter.(AckAdapter)
+	if !ok {
+		return nil, ErrAckNotSupported
+	}
+
 	args := make([]interface{}, 0, len(data)+1)
 	args = append(args, event)
 	args = append(args, data...)
@@ -217,5 +652,217 @@ func (b *BroadcastOperator) Emit(event string, data ...interface{}) error {
 		Data:      args,
 	}
 
-	return b.namespace.adapter.Broadcast(packet, b.rooms, b.except)
+	collector := newAckCollector(adapter.ServerCount())
+	if err := adapter.BroadcastWithAck(ctx, packet, b.rooms, b.except, collector.addTargets, collector.addResponse); err != nil {
+		return nil, err
+	}
+
+	return collector, nil
+}
+
+// FetchSockets returns the targeted sockets, wherever they are connected.
+//
+// With an adapter spanning several servers, it waits until every server has
+// answered, the timeout set with Timeout expires or ctx is done.
+//
+// Example:
+//
+//	sockets, err := server.To("room1").FetchSockets(ctx)
+//	for _, socket := range sockets {
+//	    log.Printf("Socket %s is in rooms %v", socket.ID, socket.Rooms)
+//	}
+func (b *BroadcastOperator) FetchSockets(ctx context.Context) ([]*RemoteSocket, error) {
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+
+	var sockets []*RemoteSocket
+	if adapter, ok := b.clusterAdapter(); ok {
+		var err error
+		if sockets, err = adapter.FetchSockets(ctx, b.rooms, b.except); err != nil {
+			return nil, err
+		}
+	} else {
+		sockets = remoteSockets(b.localSockets())
+	}
+
+	for _, socket := range sockets {
+		socket.namespace = b.namespace
+	}
+	return sockets, nil
+}
+
+// SocketsJoin makes the targeted sockets join the rooms, wherever they are
+// connected.
+//
+// Example:
+//
+//	server.To("room1").SocketsJoin("room2", "room3")
+func (b *BroadcastOperator) SocketsJoin(rooms ...string) error {
+	if adapter, ok := b.clusterAdapter(); ok {
+		return adapter.AddSockets(b.rooms, b.except, rooms)
+	}
+	joinSockets(b.localSockets(), rooms)
+	return nil
+}
+
+// SocketsLeave makes the targeted sockets leave the rooms, wherever they are
+// connected.
+//
+// Example:
+//
+//	server.To("room1").SocketsLeave("room2")
+func (b *BroadcastOperator) SocketsLeave(rooms ...string) error {
+	if adapter, ok := b.clusterAdapter(); ok {
+		return adapter.DelSockets(b.rooms, b.except, rooms)
+	}
+	leaveSockets(b.localSockets(), rooms)
+	return nil
+}
+
+// DisconnectSockets disconnects the targeted sockets from the namespace,
+// wherever they are connected. If close is true, their underlying connection
+// is closed instead.
+//
+// Example:
+//
+//	// Kick everyone from the room
+//	server.To("room1").DisconnectSockets(true)
+func (b *BroadcastOperator) DisconnectSockets(close bool) error {
+	if adapter, ok := b.clusterAdapter(); ok {
+		return adapter.DisconnectSockets(b.rooms, b.except, close)
+	}
+	disconnectSockets(b.localSockets(), close)
+	return nil
+}
+
+// clusterAdapter returns the namespace adapter if it implements ClusterAdapter
+// and the operator is not restricted to the local server
+func (b *BroadcastOperator) clusterAdapter() (ClusterAdapter, bool) {
+	if b.local {
+		return nil, false
+	}
+	adapter, ok := b.namespace.adapter.(ClusterAdapter)
+	return adapter, ok
+}
+
+// localSockets returns the targeted sockets connected to this server
+func (b *BroadcastOperator) localSockets() []*Socket {
+	except := make(map[string]bool, len(b.except))
+	for _, id := range b.except {
+		except[id] = true
+	}
+
+	var sockets []*Socket
+	for _, socket := range b.namespace.Sockets() {
+		if except[socket.id] {
+			continue
+		}
+		if len(b.rooms) == 0 || socket.inAnyRoom(b.rooms) {
+			sockets = append(sockets, socket)
+		}
+	}
+	return sockets
+}
+
+// ackCollector gathers the acknowledgments of a broadcast until every targeted
+// socket on every server has answered.
+type ackCollector struct {
+	mu        sync.Mutex
+	servers   int // servers that have not reported their targets yet
+	targets   map[string]bool
+	responses map[string][]interface{}
+	failures  map[string]bool
+	err       error // error of the first failed target
+	done      chan struct{}
+	finished  bool
+}
+
+func newAckCollector(servers int) *ackCollector {
+	return &ackCollector{
+		servers:   servers,
+		targets:   make(map[string]bool),
+		responses: make(map[string][]interface{}),
+		failures:  make(map[string]bool),
+		done:      make(chan struct{}),
+	}
+}
+
+func (c *ackCollector) addTargets(socketIDs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.servers--
+	for _, id := range socketIDs {
+		c.targets[id] = true
+	}
+	c.check()
+}
+
+// addResponse records the acknowledgment of a socket, or its failure to
+// answer if err is not nil
+func (c *ackCollector) addResponse(socketID string, args []interface{}, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.finished {
+		return
+	}
+	if err != nil {
+		c.failures[socketID] = true
+		if c.err == nil {
+			c.err = err
+		}
+	} else {
+		c.responses[socketID] = args
+	}
+	c.check()
+}
+
+// check finishes the collection once every target has answered or failed
+func (c *ackCollector) check() {
+	if c.finished || c.servers > 0 {
+		return
+	}
+	for id := range c.targets {
+		if _, ok := c.responses[id]; !ok && !c.failures[id] {
+			return
+		}
+	}
+	c.finished = true
+	close(c.done)
+}
+
+func (c *ackCollector) wait(ctx context.Context) (*BroadcastAckResult, error) {
+	var err error
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.finished {
+		c.finished = true
+		close(c.done)
+	}
+
+	result := &BroadcastAckResult{
+		Responses: make(map[string][]interface{}, len(c.responses)),
+	}
+	for id := range c.targets {
+		if args, ok := c.responses[id]; ok {
+			result.Responses[id] = args
+		} else {
+			result.Missing = append(result.Missing, id)
+		}
+	}
+
+	if len(result.Missing) == 0 {
+		err = nil
+	} else if err == nil {
+		err = c.err
+	}
+	return result, err
 }
@@ -0,0 +1,86 @@
+package gosocketio_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+func TestConnectInvalidNamespace(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.On("echo", echo)
+	})
+	server.Of("/chat")
+
+	_, err := server.Dial("/unknown", nil)
+	var connectErr *gosocketio.ConnectError
+	if !errors.As(err, &connectErr) || connectErr.Message != "Invalid namespace" {
+		t.Fatalf("Dial = %v, want Invalid namespace", err)
+	}
+
+	// The refusal leaves the other namespaces of the connection alone
+	alice := server.Connect(t, "/")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err = alice.Manager().Socket("/unknown").Connect(ctx)
+	if !errors.As(err, &connectErr) || connectErr.Message != "Invalid namespace" {
+		t.Fatalf("Connect = %v, want Invalid namespace", err)
+	}
+	if err := alice.Manager().Socket("/chat").Connect(ctx); err != nil {
+		t.Fatalf("Connect(/chat) = %v", err)
+	}
+	expectEcho(t, alice)
+}
+
+func TestConnectErrorData(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	chat := server.Of("/chat")
+	chat.Use(func(socket *gosocketio.Socket, next func(error)) {
+		switch socket.Handshake().Auth["token"] {
+		case "valid":
+			next(nil)
+		case nil:
+			next(errors.New("token required"))
+		default:
+			next(&gosocketio.ConnectError{
+				Message: "unauthorized",
+				Data:    map[string]interface{}{"code": 401, "reasons": []string{"expired"}},
+			})
+		}
+	})
+
+	dial := func(token interface{}) (*siotest.Client, error) {
+		opts := server.Options()
+		if token != nil {
+			opts.Auth = map[string]interface{}{"token": token}
+		}
+		return server.Dial("/chat", opts)
+	}
+
+	_, err := dial("expired")
+	var connectErr *gosocketio.ConnectError
+	if !errors.As(err, &connectErr) || connectErr.Message != "unauthorized" {
+		t.Fatalf("Dial = %v, want the ConnectError", err)
+	}
+	data, _ := connectErr.Data.(map[string]interface{})
+	reasons, _ := data["reasons"].([]interface{})
+	if data["code"] != float64(401) || len(reasons) != 1 || reasons[0] != "expired" {
+		t.Fatalf("ConnectError data = %#v, want the middleware data", connectErr.Data)
+	}
+
+	// Plain errors only carry their message
+	_, err = dial(nil)
+	if !errors.As(err, &connectErr) || connectErr.Message != "token required" || connectErr.Data != nil {
+		t.Fatalf("Dial = %#v, want the error message without data", err)
+	}
+
+	if _, err := dial("valid"); err != nil {
+		t.Fatalf("Dial with a valid token = %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package gosocketio_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/engineio"
+)
+
+// connectStalled connects a long-polling client to the main namespace that
+// stops polling once connected, so that packets sent to it stay queued
+func connectStalled(t *testing.T, server *gosocketio.Server) {
+	t.Helper()
+
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	url := ts.URL + "/socket.io/?EIO=4&transport=polling"
+	handshake := get(t, url)
+	sid, _, _ := strings.Cut(strings.TrimPrefix(handshake, `0{"sid":"`), `"`)
+	url += "&sid=" + sid
+
+	resp, err := http.Post(url, "text/plain", strings.NewReader("40"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if connect := get(t, url); !strings.HasPrefix(connect, "40") {
+		t.Fatalf("got %q, want a CONNECT packet", connect)
+	}
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestBroadcastReportsSlowClients(t *testing.T) {
+	server := gosocketio.NewServer(&gosocketio.Config{MaxQueuedPackets: 1})
+	t.Cleanup(func() { server.Close() })
+	connectStalled(t, server)
+
+	if err := server.Emit("first"); err != nil {
+		t.Fatalf("first Emit = %v", err)
+	}
+
+	err := server.Emit("second")
+	var broadcastErr *gosocketio.BroadcastError
+	if !errors.As(err, &broadcastErr) || len(broadcastErr.Errors) != 1 {
+		t.Fatalf("second Emit = %v, want a BroadcastError for one socket", err)
+	}
+	if !errors.Is(err, engineio.ErrSlowClient) {
+		t.Fatalf("second Emit = %v, want ErrSlowClient", err)
+	}
+}
+
+func TestBroadcastBlockWithContext(t *testing.T) {
+	server := gosocketio.NewServer(&gosocketio.Config{
+		MaxQueuedPackets: 1,
+		OverflowPolicy:   engineio.OverflowBlock,
+	})
+	t.Cleanup(func() { server.Close() })
+	connectStalled(t, server)
+
+	if err := server.Emit("first"); err != nil {
+		t.Fatalf("first Emit = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.To().EmitContext(ctx, "second"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("EmitContext = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -24,8 +24,17 @@ const (
 type Packet struct {
 	Type      PacketType
 	Namespace string
-	Data      interface{}
-	ID        *int
+
+	// Data is the payload of the packet. DecodePacket keeps it as the
+	// json.RawMessage received, so that it is only decoded when needed and
+	// typed handlers can decode arguments directly into structs; use
+	// DecodedData to get interface{} values.
+	Data interface{}
+	ID   *int
+
+	// Attachments is the number of binary attachments that follow a
+	// BINARY_EVENT or BINARY_ACK packet.
+	Attachments int
 }
 
 // Encode encodes a Socket.IO packet to string
@@ -35,6 +44,12 @@ func (p *Packet) Encode() (string, error) {
 	// Packet type
 	builder.WriteString(strconv.Itoa(int(p.Type)))
 
+	// Attachment count
+	if p.Type == PacketTypeBinaryEvent || p.Type == PacketTypeBinaryAck {
+		builder.WriteString(strconv.Itoa(p.Attachments))
+		builder.WriteByte('-')
+	}
+
 	// Namespace (if not default)
 	if p.Namespace != "" && p.Namespace != "/" {
 		builder.WriteString(p.Namespace)
@@ -77,6 +92,20 @@ func DecodePacket(data string) (*Packet, error) {
 	packet.Type = PacketType(data[pos] - '0')
 	pos++
 
+	// Parse attachment count
+	if packet.Type == PacketTypeBinaryEvent || packet.Type == PacketTypeBinaryAck {
+		end := strings.IndexByte(data[pos:], '-')
+		if end == -1 {
+			return nil, fmt.Errorf("missing attachment count")
+		}
+		attachments, err := strconv.Atoi(data[pos : pos+end])
+		if err != nil || attachments < 0 {
+			return nil, fmt.Errorf("invalid attachment count: %s", data[pos:pos+end])
+		}
+		packet.Attachments = attachments
+		pos += end + 1
+	}
+
 	if pos >= len(data) {
 		return packet, nil
 	}
@@ -114,14 +143,38 @@ func DecodePacket(data string) (*Packet, error) {
 
 	// Parse data
 	if pos < len(data) {
-		if err := json.Unmarshal([]byte(data[pos:]), &packet.Data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal packet data: %w", err)
+		if !json.Valid([]byte(data[pos:])) {
+			return nil, fmt.Errorf("invalid packet data")
 		}
+		packet.Data = json.RawMessage(data[pos:])
 	}
 
 	return packet, nil
 }
 
+// DecodedData returns the packet data, decoded into interface{} values if
+// it is raw JSON.
+func (p *Packet) DecodedData() (interface{}, error) {
+	raw, ok := p.Data.(json.RawMessage)
+	if !ok {
+		return p.Data, nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal packet data: %w", err)
+	}
+	return data, nil
+}
+
+// decodedArgs returns the packet data as an argument list, or nil if it is
+// not one
+func (p *Packet) decodedArgs() []interface{} {
+	data, _ := p.DecodedData()
+	args, _ := data.([]interface{})
+	return args
+}
+
 // String returns the packet type as a string
 func (pt PacketType) String() string {
 	switch pt {
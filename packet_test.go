@@ -0,0 +1,60 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodePacketKeepsRawData(t *testing.T) {
+	packet, err := DecodePacket(`2/chat,12["message",{"text":"hi"}]`)
+	if err != nil {
+		t.Fatalf("DecodePacket: %v", err)
+	}
+	if packet.Namespace != "/chat" || packet.ID == nil || *packet.ID != 12 {
+		t.Fatalf("got namespace %q and ID %v", packet.Namespace, packet.ID)
+	}
+
+	raw, ok := packet.Data.(json.RawMessage)
+	if !ok || string(raw) != `["message",{"text":"hi"}]` {
+		t.Fatalf("got data %#v, want the raw JSON", packet.Data)
+	}
+
+	data, err := packet.DecodedData()
+	if err != nil {
+		t.Fatalf("DecodedData: %v", err)
+	}
+	if args, _ := data.([]interface{}); len(args) != 2 || args[0] != "message" {
+		t.Fatalf("got decoded data %v", data)
+	}
+}
+
+func TestDecodePacketInvalidData(t *testing.T) {
+	if _, err := DecodePacket(`2["message",`); err == nil {
+		t.Fatal("DecodePacket accepted invalid JSON")
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	packet, err := DecodePacket(`2["message",1,"two"]`)
+	if err != nil {
+		t.Fatalf("DecodePacket: %v", err)
+	}
+
+	event, args, ok := parseEvent(packet)
+	if !ok || event != "message" {
+		t.Fatalf("got event %q, ok %v", event, ok)
+	}
+	if len(args.raw) != 2 {
+		t.Fatalf("got %d raw arguments, want 2", len(args.raw))
+	}
+	if values := args.values(); len(values) != 2 || values[0] != float64(1) || values[1] != "two" {
+		t.Fatalf("got arguments %v", values)
+	}
+
+	for _, data := range []string{`2[]`, `2[1]`, `2{"message":1}`} {
+		packet, _ := DecodePacket(data)
+		if _, _, ok := parseEvent(packet); ok {
+			t.Errorf("parseEvent accepted %s", data)
+		}
+	}
+}
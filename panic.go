@@ -0,0 +1,105 @@
+package gosocketio
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// PanicError is the error reported to the Server.OnError handler when a
+// handler or callback panics.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// ErrorHandler is a function called when a handler or callback panics.
+//
+// socket is the socket the handler was running for, or nil for handlers not
+// tied to a socket. event is the name of the event being handled: "connect"
+// for connection handlers and namespace middlewares, "disconnect" for
+// disconnect handlers, and an empty string for acknowledgment callbacks.
+type ErrorHandler func(err error, socket *Socket, event string)
+
+// OnError sets the handler called when an event handler, acknowledgment
+// callback, middleware, connection or disconnect handler panics.
+//
+// Panics are always recovered so that one faulty handler cannot crash the
+// server. The error passed to the handler is a *PanicError. If no handler is
+// set, panics are logged with the standard log package.
+//
+// Example:
+//
+//	server.OnError(func(err error, socket *gosocketio.Socket, event string) {
+//	    var panicErr *gosocketio.PanicError
+//	    if errors.As(err, &panicErr) {
+//	        log.Printf("Handler for %q panicked: %v\n%s", event, panicErr.Value, panicErr.Stack)
+//	    }
+//	})
+func (s *Server) OnError(handler ErrorHandler) {
+	s.errorMu.Lock()
+	s.onError = handler
+	s.errorMu.Unlock()
+}
+
+// recoverPanic recovers from a panic and reports it. It must be deferred
+// directly.
+func (s *Server) recoverPanic(socket *Socket, event string) {
+	if value := recover(); value != nil {
+		s.reportPanic(value, socket, event)
+	}
+}
+
+// reportPanic reports a recovered panic and returns the error passed to the
+// error handler
+func (s *Server) reportPanic(value interface{}, socket *Socket, event string) *PanicError {
+	err := &PanicError{Value: value, Stack: debug.Stack()}
+
+	var handler ErrorHandler
+	if s != nil {
+		s.errorMu.RLock()
+		handler = s.onError
+		s.errorMu.RUnlock()
+	}
+
+	if handler == nil {
+		log.Printf("gosocketio: recovered %v in handler for %q\n%s", err, event, err.Stack)
+		return err
+	}
+	handler(err, socket, event)
+	return err
+}
+
+// safeCall calls fn, recovering from and reporting any panic
+func (s *Socket) safeCall(event string, fn func()) {
+	defer s.namespace.server.recoverPanic(s, event)
+	fn()
+}
+
+// runHandler calls an event handler, recovering from and reporting any panic.
+// If Config.AckOnPanic is set and the client requested an acknowledgment, an
+// error acknowledgment is sent unless the handler already answered.
+func (s *Socket) runHandler(event string, ack func(...interface{}), fn func()) {
+	defer func() {
+		value := recover()
+		if value == nil {
+			return
+		}
+
+		server := s.namespace.server
+		server.reportPanic(value, s, event)
+
+		if server == nil || !server.config.AckOnPanic || ack == nil {
+			return
+		}
+		ack(map[string]interface{}{"error": "internal server error"})
+	}()
+	fn()
+}
@@ -0,0 +1,206 @@
+package gosocketio_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+type reportedPanic struct {
+	err    error
+	socket *gosocketio.Socket
+	event  string
+}
+
+// recordPanics sends the errors reported to Server.OnError to the returned
+// channel
+func recordPanics(server *siotest.Server) chan reportedPanic {
+	panics := make(chan reportedPanic, 16)
+	server.OnError(func(err error, socket *gosocketio.Socket, event string) {
+		panics <- reportedPanic{err, socket, event}
+	})
+	return panics
+}
+
+// expectPanic waits for a panic to be reported and checks that it is a
+// *PanicError with the value and its stack, for the event
+func expectPanic(t *testing.T, panics chan reportedPanic, value interface{}, event string) reportedPanic {
+	t.Helper()
+
+	select {
+	case p := <-panics:
+		var panicErr *gosocketio.PanicError
+		if !errors.As(p.err, &panicErr) || panicErr.Value != value || len(panicErr.Stack) == 0 {
+			t.Fatalf("got error %#v, want a *PanicError for %v with its stack", p.err, value)
+		}
+		if p.event != event {
+			t.Fatalf("panic reported for event %q, want %q", p.event, event)
+		}
+		return p
+	case <-time.After(time.Second):
+		t.Fatalf("panic %v not reported", value)
+		return reportedPanic{}
+	}
+}
+
+// echo acknowledges an event with its arguments
+func echo(args ...interface{}) {
+	ack := args[len(args)-1].(func(...interface{}))
+	ack(args[:len(args)-1]...)
+}
+
+// expectEcho checks that the socket of the client still handles events
+func expectEcho(t *testing.T, client *siotest.Client) {
+	t.Helper()
+
+	if response := client.ExpectAck(t, time.Second, "echo", "still there"); len(response) != 1 || response[0] != "still there" {
+		t.Fatalf("got %v, want the echo", response)
+	}
+}
+
+func TestPanicInEventHandler(t *testing.T) {
+	modes := []struct {
+		name string
+		mode gosocketio.DispatchMode
+	}{
+		{"concurrent", gosocketio.DispatchConcurrent},
+		{"ordered", gosocketio.DispatchOrdered},
+		{"ordered per event", gosocketio.DispatchOrderedPerEvent},
+		{"pool", gosocketio.DispatchPool},
+	}
+
+	for _, tt := range modes {
+		t.Run(tt.name, func(t *testing.T) {
+			server := siotest.NewServer(t, &gosocketio.Config{DispatchMode: tt.mode})
+			panics := recordPanics(server)
+			server.OnConnect(func(socket *gosocketio.Socket) {
+				socket.On("work", func(args ...interface{}) {
+					if args[0] == "panic" {
+						panic("handler")
+					}
+					args[len(args)-1].(func(...interface{}))("done")
+				})
+				socket.On("echo", echo)
+			})
+			alice := server.Connect(t, "/")
+
+			for i := 0; i < 3; i++ {
+				if err := alice.Emit("work", "panic"); err != nil {
+					t.Fatalf("Emit: %v", err)
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if p := expectPanic(t, panics, "handler", "work"); p.socket == nil {
+					t.Fatal("panic reported without its socket")
+				}
+			}
+
+			// The queue of the event and the other queues keep running
+			if response := alice.ExpectAck(t, time.Second, "work", "ok"); len(response) != 1 || response[0] != "done" {
+				t.Fatalf("got %v, want [done]", response)
+			}
+			expectEcho(t, alice)
+		})
+	}
+}
+
+func TestPanicInAckCallback(t *testing.T) {
+	server := siotest.NewServer(t, &gosocketio.Config{DispatchMode: gosocketio.DispatchOrdered})
+	panics := recordPanics(server)
+	sockets := make(chan *gosocketio.Socket, 1)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.On("echo", echo)
+		sockets <- socket
+	})
+	alice := server.Connect(t, "/")
+	socket := <-sockets
+
+	socket.EmitWithAck("question", func(response ...interface{}) {
+		panic("ack")
+	})
+	alice.ExpectEvent(t, "question", time.Second).Ack("answer")
+	expectPanic(t, panics, "ack", "")
+
+	answers := make(chan interface{}, 1)
+	socket.EmitWithAck("question", func(response ...interface{}) {
+		answers <- response[0]
+	})
+	alice.ExpectEvent(t, "question", time.Second).Ack("again")
+	select {
+	case answer := <-answers:
+		if answer != "again" {
+			t.Fatalf("got answer %v, want again", answer)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acknowledgment not received after a panicking callback")
+	}
+	expectEcho(t, alice)
+}
+
+func TestPanicInConnectHandler(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	panics := recordPanics(server)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.On("echo", echo)
+		panic("connect")
+	})
+
+	alice := server.Connect(t, "/")
+	if p := expectPanic(t, panics, "connect", "connect"); p.socket == nil || p.socket.ID() != alice.ID() {
+		t.Fatalf("panic reported for socket %v, want %s", p.socket, alice.ID())
+	}
+	expectEcho(t, alice)
+}
+
+func TestPanicInDisconnectHandler(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	panics := recordPanics(server)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.On("echo", echo)
+		socket.OnDisconnect(func(reason string) {
+			panic("disconnect")
+		})
+	})
+
+	alice := server.Connect(t, "/")
+	alice.Disconnect()
+	expectPanic(t, panics, "disconnect", "disconnect")
+
+	bob := server.Connect(t, "/")
+	expectEcho(t, bob)
+}
+
+func TestPanicInServerSideHandler(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	panics := recordPanics(server)
+	ns := server.Of("/")
+	ns.OnServerSideEvent("sync", func(args ...interface{}) {
+		if args[0] == "panic" {
+			panic("server-side")
+		}
+		args[len(args)-1].(func(...interface{}))("synced")
+	})
+
+	event := func(data string) *gosocketio.Packet {
+		return &gosocketio.Packet{Type: gosocketio.PacketTypeEvent, Namespace: "/", Data: []interface{}{"sync", data}}
+	}
+
+	ns.HandleServerSideEvent(event("panic"), func(args ...interface{}) {})
+	if p := expectPanic(t, panics, "server-side", "sync"); p.socket != nil {
+		t.Fatalf("panic reported for socket %s, want none", p.socket.ID())
+	}
+
+	responses := make(chan interface{}, 1)
+	ns.HandleServerSideEvent(event("ok"), func(args ...interface{}) { responses <- args[0] })
+	select {
+	case response := <-responses:
+		if response != "synced" {
+			t.Fatalf("got %v, want synced", response)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server-side event not handled after a panic")
+	}
+}
@@ -0,0 +1,187 @@
+package gosocketio
+
+import (
+	"regexp"
+	"sync"
+)
+
+// NamespaceMatcher reports whether a client may connect to the namespace with
+// the given name. auth is the auth payload sent by the client, or nil.
+type NamespaceMatcher func(name string, auth map[string]interface{}) bool
+
+// ParentNamespace creates child namespaces on demand for the names it matches.
+//
+// Children are created the first time a client connects to a matching name
+// that is not already a namespace. They run the middlewares registered on the
+// parent before their own, and call the parent connection handler in addition
+// to their own. Namespaces created with Server.Of take precedence over parents.
+//
+// If Config.CleanupEmptyChildNamespaces is set, a child is removed once its
+// last socket disconnects.
+//
+// Example:
+//
+//	tenants := server.OfRegexp(regexp.MustCompile(`^/tenant-\d+$`))
+//	tenants.OnConnect(func(socket *gosocketio.Socket) {
+//	    tenant := socket.Namespace().Name()
+//	    log.Printf("Client connected to %s", tenant)
+//	})
+type ParentNamespace struct {
+	server   *Server
+	match    NamespaceMatcher
+	children map[string]*Namespace
+	mu       sync.RWMutex
+
+	onConnect   func(*Socket)
+	middlewares []MiddlewareFunc
+}
+
+// OfFunc returns a parent namespace creating child namespaces for every name
+// accepted by match.
+//
+// Parents are tried in the order they were registered.
+//
+// Example:
+//
+//	server.OfFunc(func(name string, auth map[string]interface{}) bool {
+//	    return strings.HasPrefix(name, "/tenant-") && auth["token"] != nil
+//	})
+func (s *Server) OfFunc(match NamespaceMatcher) *ParentNamespace {
+	parent := &ParentNamespace{
+		server:   s,
+		match:    match,
+		children: make(map[string]*Namespace),
+	}
+
+	s.nsMu.Lock()
+	s.parents = append(s.parents, parent)
+	s.nsMu.Unlock()
+
+	return parent
+}
+
+// OfRegexp returns a parent namespace creating child namespaces for every name
+// matching re.
+//
+// Example:
+//
+//	server.OfRegexp(regexp.MustCompile(`^/tenant-\d+$`))
+func (s *Server) OfRegexp(re *regexp.Regexp) *ParentNamespace {
+	return s.OfFunc(func(name string, auth map[string]interface{}) bool {
+		return re.MatchString(name)
+	})
+}
+
+// OnConnect sets the connection handler for every child namespace.
+//
+// It is called after the connection handler of the child itself, if any.
+func (p *ParentNamespace) OnConnect(handler func(*Socket)) {
+	p.mu.Lock()
+	p.onConnect = handler
+	p.mu.Unlock()
+}
+
+// Use registers a middleware that runs for every incoming connection to any
+// child namespace, before the middlewares of the child itself.
+func (p *ParentNamespace) Use(fn MiddlewareFunc) {
+	p.mu.Lock()
+	p.middlewares = append(p.middlewares, fn)
+	p.mu.Unlock()
+}
+
+// Children returns the child namespaces created so far.
+func (p *ParentNamespace) Children() []*Namespace {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	children := make([]*Namespace, 0, len(p.children))
+	for _, child := range p.children {
+		children = append(children, child)
+	}
+	return children
+}
+
+// Emit broadcasts an event to every socket of every child namespace.
+//
+// Example:
+//
+//	tenants.Emit("maintenance", "Back in 5 minutes")
+func (p *ParentNamespace) Emit(event string, data ...interface{}) error {
+	for _, child := range p.Children() {
+		if err := child.Emit(event, data...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handlers returns the connection handler and middlewares shared by children
+func (p *ParentNamespace) handlers() (func(*Socket), []MiddlewareFunc) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	middlewares := make([]MiddlewareFunc, len(p.middlewares))
+	copy(middlewares, p.middlewares)
+	return p.onConnect, middlewares
+}
+
+// parentNamespace returns the child namespace with the given name of the
+// first parent matching it, creating the child if needed. Like
+// reserveNamespace, it counts a pending connection to the child.
+func (s *Server) parentNamespace(name string, auth map[string]interface{}) (*Namespace, bool) {
+	s.nsMu.RLock()
+	parents := make([]*ParentNamespace, len(s.parents))
+	copy(parents, s.parents)
+	s.nsMu.RUnlock()
+
+	for _, parent := range parents {
+		if !parent.match(name, auth) {
+			continue
+		}
+
+		s.nsMu.Lock()
+		defer s.nsMu.Unlock()
+
+		// Another client may have created it in the meantime
+		if ns, exists := s.namespaces[name]; exists {
+			ns.reserve()
+			return ns, true
+		}
+
+		ns := NewNamespace(name, s)
+		ns.parent = parent
+		ns.reserve()
+		s.namespaces[name] = ns
+
+		parent.mu.Lock()
+		parent.children[name] = ns
+		parent.mu.Unlock()
+
+		return ns, true
+	}
+
+	return nil, false
+}
+
+// removeChild removes a child namespace that has no sockets and no pending
+// connections
+func (s *Server) removeChild(ns *Namespace) {
+	s.nsMu.Lock()
+	defer s.nsMu.Unlock()
+
+	ns.mu.RLock()
+	empty := len(ns.sockets) == 0 && ns.pending == 0
+	ns.mu.RUnlock()
+
+	if !empty || s.namespaces[ns.name] != ns {
+		return
+	}
+
+	delete(s.namespaces, ns.name)
+
+	ns.parent.mu.Lock()
+	delete(ns.parent.children, ns.name)
+	ns.parent.mu.Unlock()
+
+	ns.adapter.Close()
+}
@@ -0,0 +1,67 @@
+package gosocketio_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+func TestChildNamespaceKeptWhileConnecting(t *testing.T) {
+	server := siotest.NewServer(t, &gosocketio.Config{CleanupEmptyChildNamespaces: true})
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	tenants := server.OfRegexp(regexp.MustCompile(`^/tenant-\d+$`))
+	tenants.Use(func(socket *gosocketio.Socket, next func(error)) {
+		if socket.Auth()["slow"] != nil {
+			close(entered)
+			<-release
+		}
+		next(nil)
+	})
+
+	// Alice waits in the middlewares while bob connects and leaves, which
+	// would remove the child namespace if alice were not counted
+	opts := server.Options()
+	opts.Auth = map[string]interface{}{"slow": true}
+	connected := make(chan *siotest.Client, 1)
+	go func() {
+		alice, err := server.Dial("/tenant-1", opts)
+		if err != nil {
+			t.Errorf("Dial: %v", err)
+		}
+		connected <- alice
+	}()
+	<-entered
+
+	bob := server.Connect(t, "/tenant-1")
+	bob.Disconnect()
+	time.Sleep(50 * time.Millisecond)
+
+	close(release)
+	alice := <-connected
+	if alice == nil {
+		t.FailNow()
+	}
+
+	children := tenants.Children()
+	if len(children) != 1 {
+		t.Fatalf("got %d children, want 1", len(children))
+	}
+	if err := children[0].Emit("hello"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	alice.ExpectEvent(t, "hello", time.Second)
+
+	alice.Disconnect()
+	deadline := time.Now().Add(time.Second)
+	for len(tenants.Children()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("empty child namespace not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,98 @@
+package gosocketio
+
+import "github.com/ramory-l/gosocketio/engineio"
+
+// Frame is an Engine.IO message carrying an encoded packet, or one of its
+// binary attachments.
+type Frame struct {
+	Data   []byte
+	Binary bool
+}
+
+// Parser encodes and decodes Socket.IO packets.
+//
+// The default parser is JSONParser. Another parser, such as the msgpack one
+// from the msgpackparser package, can be set with Config.Parser; clients must
+// then use a compatible parser.
+type Parser interface {
+	// Encode encodes a packet into the frames that carry it, in order.
+	Encode(packet *Packet) ([]Frame, error)
+
+	// NewDecoder returns a decoder for the frames received on one connection.
+	NewDecoder() Decoder
+}
+
+// Decoder decodes the frames received on one connection.
+type Decoder interface {
+	// Add decodes a frame. It returns nil if the packet is waiting for more
+	// frames, such as binary attachments.
+	Add(frame Frame) (*Packet, error)
+}
+
+// DefaultMaxAttachments is the default maximum number of binary attachments
+// of a packet received by JSONParser
+const DefaultMaxAttachments = 10
+
+// JSONParser is the default Socket.IO parser: packets are encoded as text
+// with JSON data, followed by one binary frame per binary attachment.
+type JSONParser struct {
+	// MaxAttachments is the maximum number of binary attachments a received
+	// packet may declare (default: DefaultMaxAttachments). Decoders reject
+	// packets declaring more instead of buffering their attachments.
+	MaxAttachments int
+}
+
+// Encode encodes a packet as a text frame followed by its binary attachments.
+func (JSONParser) Encode(packet *Packet) ([]Frame, error) {
+	encoded, buffers, err := packet.EncodeWithAttachments()
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]Frame, 0, len(buffers)+1)
+	frames = append(frames, Frame{Data: []byte(encoded)})
+	for _, buffer := range buffers {
+		frames = append(frames, Frame{Data: buffer, Binary: true})
+	}
+
+	return frames, nil
+}
+
+// NewDecoder returns a decoder reassembling packets with their binary attachments.
+func (p JSONParser) NewDecoder() Decoder {
+	maxAttachments := p.MaxAttachments
+	if maxAttachments <= 0 {
+		maxAttachments = DefaultMaxAttachments
+	}
+	return &binaryDecoder{maxAttachments: maxAttachments}
+}
+
+// encodePacket encodes a packet into the Engine.IO messages that carry it
+func encodePacket(parser Parser, packet *Packet) ([]*engineio.Packet, error) {
+	frames, err := parser.Encode(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	packets := make([]*engineio.Packet, 0, len(frames))
+	for _, frame := range frames {
+		packets = append(packets, &engineio.Packet{
+			Type:   engineio.PacketTypeMessage,
+			Data:   frame.Data,
+			Binary: frame.Binary,
+		})
+	}
+
+	return packets, nil
+}
+
+// parser returns the parser configured for the server
+func (s *Server) parser() Parser {
+	if s == nil {
+		return JSONParser{}
+	}
+	if s.config.Parser == nil {
+		return JSONParser{MaxAttachments: s.config.MaxAttachments}
+	}
+	return s.config.Parser
+}
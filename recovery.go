@@ -0,0 +1,92 @@
+package gosocketio
+
+import "time"
+
+// RecoveryConfig configures connection state recovery.
+//
+// When enabled, the state of a socket that lost its connection (socket ID,
+// rooms, data set with Socket.Set and the broadcasts it missed) is kept for
+// MaxDisconnectionDuration. A client that reconnects within that window with
+// its previous private session ID and the offset of the last packet it
+// received gets its state back, and Socket.Recovered reports true.
+//
+// Only abrupt disconnections (ping timeout, transport errors) are recoverable.
+// Sockets disconnected on purpose by the client or the server are not.
+type RecoveryConfig struct {
+	// MaxDisconnectionDuration is how long a session is kept after the
+	// connection is lost, in milliseconds (default: 120000)
+	MaxDisconnectionDuration int
+
+	// MaxBufferedPackets is the maximum number of broadcast packets kept for
+	// replay per namespace (default: 1000)
+	MaxBufferedPackets int
+
+	// SkipMiddlewares skips namespace middlewares for recovered sockets.
+	SkipMiddlewares bool
+}
+
+// SessionState is the state of a disconnected socket kept for recovery.
+type SessionState struct {
+	// SID is the socket ID.
+	SID string
+
+	// PID is the private session ID, only known to the client.
+	PID string
+
+	// Rooms are the rooms the socket had joined.
+	Rooms []string
+
+	// Data is the data stored on the socket with Set.
+	Data map[string]interface{}
+
+	// MissedPackets are the broadcast packets sent to the socket's rooms
+	// since it disconnected. It is only set by RestoreSession.
+	MissedPackets []*Packet
+
+	// DisconnectedAt is when the socket disconnected.
+	DisconnectedAt time.Time
+}
+
+// SessionAwareAdapter is an optional interface for adapters that support
+// connection state recovery. MemoryAdapter implements it.
+//
+// Adapters implementing it must append an offset to the data of every
+// broadcast EVENT packet sent without an ack when recovery is enabled, and
+// keep those packets for replay.
+type SessionAwareAdapter interface {
+	Adapter
+
+	// PersistSession stores the state of a socket that lost its connection.
+	PersistSession(session *SessionState)
+
+	// RestoreSession removes and returns the stored session with the given
+	// private session ID, along with the packets it missed after offset.
+	// It returns false if the session is unknown or has expired, or if the
+	// offset is no longer buffered.
+	RestoreSession(pid, offset string) (*SessionState, bool)
+}
+
+// recoverableReasons are the disconnect reasons after which a socket state
+// is kept for recovery
+var recoverableReasons = map[string]bool{
+	"transport close": true,
+	"transport error": true,
+	"ping timeout":    true,
+	"read error":      true,
+	"write error":     true,
+	"parse error":     true,
+}
+
+func (c *RecoveryConfig) maxDisconnectionDuration() time.Duration {
+	if c.MaxDisconnectionDuration <= 0 {
+		return 2 * time.Minute
+	}
+	return time.Duration(c.MaxDisconnectionDuration) * time.Millisecond
+}
+
+func (c *RecoveryConfig) maxBufferedPackets() int {
+	if c.MaxBufferedPackets <= 0 {
+		return 1000
+	}
+	return c.MaxBufferedPackets
+}
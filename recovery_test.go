@@ -0,0 +1,120 @@
+package gosocketio_test
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+// joinBroadcaster is a MemoryAdapter broadcasting a live event to a room as
+// soon as a socket joins it, once armed
+type joinBroadcaster struct {
+	*gosocketio.MemoryAdapter
+	ns    *gosocketio.Namespace
+	armed atomic.Bool
+}
+
+func (a *joinBroadcaster) Add(socketID, room string) {
+	a.MemoryAdapter.Add(socketID, room)
+	if room == "news" && a.armed.CompareAndSwap(true, false) {
+		a.ns.To("news").Emit("news", "live")
+	}
+}
+
+// readEvent reads an EVENT packet and returns its arguments
+func (c *rawClient) readEvent() []interface{} {
+	c.t.Helper()
+
+	data := c.read()
+	var args []interface{}
+	if !strings.HasPrefix(data, "42") || json.Unmarshal([]byte(data[2:]), &args) != nil {
+		c.t.Fatalf("got %q, want an EVENT packet", data)
+	}
+	return args
+}
+
+func TestConnectionStateRecovery(t *testing.T) {
+	var adapter *joinBroadcaster
+	server := siotest.NewServer(t, &gosocketio.Config{
+		ConnectionStateRecovery: &gosocketio.RecoveryConfig{},
+		AdapterFactory: func(ns *gosocketio.Namespace) gosocketio.Adapter {
+			adapter = &joinBroadcaster{MemoryAdapter: gosocketio.NewMemoryAdapter(ns), ns: ns}
+			return adapter
+		},
+	})
+
+	sockets := make(chan *gosocketio.Socket, 2)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		if !socket.Recovered() {
+			socket.Join("news")
+		}
+		sockets <- socket
+	})
+
+	first, _ := dialRaw(t, server, "4")
+	first.send("40")
+	var session struct {
+		SID string `json:"sid"`
+		PID string `json:"pid"`
+	}
+	if connect := first.read(); !strings.HasPrefix(connect, "40") || json.Unmarshal([]byte(connect[2:]), &session) != nil || session.PID == "" {
+		t.Fatalf("got %q, want a CONNECT packet with a private session ID", connect)
+	}
+	socket := <-sockets
+
+	// The last argument of broadcasts is their offset
+	server.To("news").Emit("news", "before")
+	args := first.readEvent()
+	offset, _ := args[len(args)-1].(string)
+	if len(args) != 3 || args[1] != "before" || offset == "" {
+		t.Fatalf("got %v, want the broadcast with its offset", args)
+	}
+
+	disconnected := make(chan string, 1)
+	socket.OnDisconnect(func(reason string) { disconnected <- reason })
+	first.conn.Close()
+	<-disconnected
+
+	server.To("news").Emit("news", "missed 1")
+	server.To("elsewhere").Emit("news", "not for the socket")
+	server.To("news").Emit("news", "missed 2")
+
+	// A broadcast sent while the socket rejoins its rooms must not overtake
+	// the missed ones
+	adapter.armed.Store(true)
+
+	second, _ := dialRaw(t, server, "4")
+	second.send(`40{"pid":"` + session.PID + `","offset":"` + offset + `"}`)
+	var restored struct {
+		SID string `json:"sid"`
+		PID string `json:"pid"`
+	}
+	if connect := second.read(); !strings.HasPrefix(connect, "40") || json.Unmarshal([]byte(connect[2:]), &restored) != nil {
+		t.Fatalf("got %q, want a CONNECT packet", connect)
+	}
+	if restored.SID != session.SID || restored.PID != session.PID {
+		t.Fatalf("reconnected as %+v, want %+v", restored, session)
+	}
+
+	for _, want := range []string{"missed 1", "missed 2", "live"} {
+		if args := second.readEvent(); args[1] != want {
+			t.Fatalf("got %v, want %q", args, want)
+		}
+	}
+
+	socket = <-sockets
+	if !socket.Recovered() || socket.ID() != session.SID {
+		t.Fatalf("socket %q recovered %v, want %q recovered", socket.ID(), socket.Recovered(), session.SID)
+	}
+	rooms := map[string]bool{}
+	for _, room := range socket.Rooms() {
+		rooms[room] = true
+	}
+	if len(rooms) != 2 || !rooms["news"] || !rooms[session.SID] {
+		t.Fatalf("socket rooms = %v, want news and its own room", socket.Rooms())
+	}
+}
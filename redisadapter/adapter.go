@@ -0,0 +1,624 @@
+// Package redisadapter provides a gosocketio.Adapter that uses Redis pub/sub
+// to broadcast packets and query rooms across several server instances.
+//
+// Every server keeps its own sockets and rooms in a gosocketio.MemoryAdapter. Broadcasts are delivered locally and published on
+// a Redis channel that every server of the namespace subscribes to, so they
+// reach sockets connected anywhere in the cluster. Room membership queries and
+// cluster operations such as FetchSockets and DisconnectSockets are handled by
+// every server through request and response channels.
+//
+// Example:
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//
+//	server := gosocketio.NewServer(&gosocketio.Config{
+//	    AdapterFactory: redisadapter.Factory(rdb, nil),
+//	})
+//
+// Any redis.UniversalClient can be used, including a client connected to an
+// in-process stand-in such as miniredis in tests.
+//
+// Connection state recovery is not supported: the adapter does not implement
+// gosocketio.SessionAwareAdapter, since the offsets of missed packets would
+// only be known to the server that sent them.
+package redisadapter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ramory-l/gosocketio"
+)
+
+// Options configures the Redis adapter.
+type Options struct {
+	// Key is the prefix of the Redis channels (default: "socket.io")
+	Key string
+
+	// RequestsTimeout is how long to wait for other servers to answer a
+	// request such as a room membership query (default: 5s)
+	RequestsTimeout time.Duration
+
+	// OnOverflow is called with the packet and the rooms of a broadcast from
+	// another server that is dropped because 1024 broadcasts are already
+	// waiting to be delivered to local sockets, to count or log lost data.
+	// A dropped broadcast with acknowledgments times out on the sender. It
+	// must not block.
+	OnOverflow func(packet *gosocketio.Packet, rooms []string)
+}
+
+// Adapter is a gosocketio.Adapter spanning every server connected to the
+// same Redis instance.
+type Adapter struct {
+	local     *gosocketio.MemoryAdapter
+	client    redis.UniversalClient
+	pubsub    *redis.PubSub
+	namespace *gosocketio.Namespace
+	uid       string
+	opts      Options
+
+	broadcastChannel string
+	requestChannel   string
+	responseChannel  string
+
+	requests   map[string]func(*message)
+	requestsMu sync.Mutex
+
+	// broadcasts queues the broadcasts received from other servers, so that
+	// a local socket slow to accept them does not hold up the other messages
+	broadcasts chan *message
+}
+
+// broadcastQueueSize is the number of broadcasts from other servers that can
+// be waiting to be delivered to local sockets
+const broadcastQueueSize = 1024
+
+var (
+	_ gosocketio.AckAdapter        = (*Adapter)(nil)
+	_ gosocketio.ClusterAdapter    = (*Adapter)(nil)
+	_ gosocketio.LocalBroadcaster  = (*Adapter)(nil)
+	_ gosocketio.ServerSideEmitter = (*Adapter)(nil)
+)
+
+// Factory returns a function creating a Redis adapter for each namespace,
+// suitable for gosocketio.Config.AdapterFactory.
+func Factory(client redis.UniversalClient, opts *Options) func(*gosocketio.Namespace) gosocketio.Adapter {
+	return func(ns *gosocketio.Namespace) gosocketio.Adapter {
+		return New(ns, client, opts)
+	}
+}
+
+// New creates a Redis adapter for the namespace and subscribes to its channels.
+//
+// If opts is nil, default options are used.
+func New(ns *gosocketio.Namespace, client redis.UniversalClient, opts *Options) *Adapter {
+	a := &Adapter{
+		local:      gosocketio.NewMemoryAdapter(ns),
+		client:     client,
+		namespace:  ns,
+		uid:        generateID(),
+		requests:   make(map[string]func(*message)),
+		broadcasts: make(chan *message, broadcastQueueSize),
+	}
+
+	if opts != nil {
+		a.opts = *opts
+	}
+	if a.opts.Key == "" {
+		a.opts.Key = "socket.io"
+	}
+	if a.opts.RequestsTimeout <= 0 {
+		a.opts.RequestsTimeout = 5 * time.Second
+	}
+
+	prefix := a.opts.Key
+	a.broadcastChannel = prefix + "#" + ns.Name() + "#"
+	a.requestChannel = prefix + "-request#" + ns.Name() + "#"
+	a.responseChannel = a.responseChannelOf(a.uid)
+
+	ctx := context.Background()
+	a.pubsub = client.Subscribe(ctx, a.broadcastChannel, a.requestChannel, a.responseChannel)
+
+	// Wait for the subscriptions to be confirmed so that ServerCount and
+	// broadcasts from other servers see this one right away
+	ctx, cancel := context.WithTimeout(ctx, a.opts.RequestsTimeout)
+	for i := 0; i < 3; i++ {
+		if _, err := a.pubsub.Receive(ctx); err != nil {
+			break
+		}
+	}
+	cancel()
+
+	go a.run()
+	go a.runBroadcasts()
+
+	return a
+}
+
+// Add adds a local socket to a room.
+func (a *Adapter) Add(socketID, room string) {
+	a.local.Add(socketID, room)
+}
+
+// Remove removes a local socket from a room.
+func (a *Adapter) Remove(socketID, room string) {
+	a.local.Remove(socketID, room)
+}
+
+// RemoveAll removes a local socket from all of its rooms.
+func (a *Adapter) RemoveAll(socketID string) {
+	a.local.RemoveAll(socketID)
+}
+
+// Broadcast sends a packet to the targeted sockets on every server. Only the
+// local sockets that could not be sent the packet are reported in the
+// returned *gosocketio.BroadcastError, along with any publishing error.
+func (a *Adapter) Broadcast(ctx context.Context, packet *gosocketio.Packet, rooms []string, except []string) error {
+	msg := &message{
+		Type:   messageBroadcast,
+		Rooms:  rooms,
+		Except: except,
+	}
+	if err := msg.setPacket(packet); err != nil {
+		return err
+	}
+
+	localErr := a.local.Broadcast(ctx, packet, rooms, except)
+	return errors.Join(localErr, a.publish(a.broadcastChannel, msg))
+}
+
+// BroadcastLocal sends a packet to the targeted sockets on this server only.
+func (a *Adapter) BroadcastLocal(ctx context.Context, packet *gosocketio.Packet, rooms []string, except []string) error {
+	return a.local.Broadcast(ctx, packet, rooms, except)
+}
+
+// BroadcastWithAck sends a packet to the targeted sockets on every server and
+// reports their acknowledgments until ctx is done.
+func (a *Adapter) BroadcastWithAck(ctx context.Context, packet *gosocketio.Packet, rooms []string, except []string,
+	onTargets func(socketIDs []string), onAck func(socketID string, args []interface{}, err error)) error {
+	if err := a.local.BroadcastWithAck(ctx, packet, rooms, except, onTargets, onAck); err != nil {
+		return err
+	}
+
+	msg := &message{
+		Type:      messageBroadcast,
+		RequestID: generateID(),
+		Rooms:     rooms,
+		Except:    except,
+		Timeout:   a.timeoutOf(ctx).Milliseconds(),
+	}
+	if err := msg.setPacket(packet); err != nil {
+		return err
+	}
+
+	a.addRequest(msg.RequestID, func(response *message) {
+		switch response.Type {
+		case messageBroadcastTargets:
+			onTargets(response.SocketIDs)
+		case messageBroadcastAck:
+			if response.Error != "" {
+				onAck(response.SocketID, nil, errors.New(response.Error))
+			} else if ack, err := response.packet(); err == nil {
+				args, _ := ack.Data.([]interface{})
+				onAck(response.SocketID, args, nil)
+			}
+		}
+	})
+
+	go func() {
+		<-ctx.Done()
+		a.removeRequest(msg.RequestID)
+	}()
+
+	return a.publish(a.broadcastChannel, msg)
+}
+
+// Sockets returns the IDs of the sockets in the room on every server.
+func (a *Adapter) Sockets(room string) []string {
+	ids := a.local.Sockets(room)
+
+	responses, _ := a.request(context.Background(), &message{Type: messageSocketsRequest, Room: room})
+	for _, response := range responses {
+		ids = append(ids, response.SocketIDs...)
+	}
+
+	return ids
+}
+
+// SocketRooms returns the rooms of a socket connected to any server.
+func (a *Adapter) SocketRooms(socketID string) []string {
+	if rooms := a.local.SocketRooms(socketID); len(rooms) > 0 {
+		return rooms
+	}
+
+	responses, _ := a.request(context.Background(), &message{Type: messageSocketRoomsRequest, SocketID: socketID})
+	for _, response := range responses {
+		if len(response.Rooms) > 0 {
+			return response.Rooms
+		}
+	}
+
+	return []string{}
+}
+
+// FetchSockets returns the matching sockets of every server.
+func (a *Adapter) FetchSockets(ctx context.Context, rooms []string, except []string) ([]*gosocketio.RemoteSocket, error) {
+	sockets, err := a.local.FetchSockets(ctx, rooms, except)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := a.request(ctx, &message{
+		Type:   messageFetchSocketsRequest,
+		Rooms:  rooms,
+		Except: except,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, response := range responses {
+		for i := range response.Sockets {
+			sockets = append(sockets, response.Sockets[i].remoteSocket())
+		}
+	}
+	return sockets, nil
+}
+
+// AddSockets makes the matching sockets of every server join the rooms in join.
+func (a *Adapter) AddSockets(rooms []string, except []string, join []string) error {
+	a.local.AddSockets(rooms, except, join)
+
+	return a.publish(a.requestChannel, &message{
+		Type:   messageAddSockets,
+		Rooms:  rooms,
+		Except: except,
+		Join:   join,
+	})
+}
+
+// DelSockets makes the matching sockets of every server leave the rooms in leave.
+func (a *Adapter) DelSockets(rooms []string, except []string, leave []string) error {
+	a.local.DelSockets(rooms, except, leave)
+
+	return a.publish(a.requestChannel, &message{
+		Type:   messageDelSockets,
+		Rooms:  rooms,
+		Except: except,
+		Leave:  leave,
+	})
+}
+
+// DisconnectSockets disconnects the matching sockets of every server.
+func (a *Adapter) DisconnectSockets(rooms []string, except []string, close bool) error {
+	a.local.DisconnectSockets(rooms, except, close)
+
+	return a.publish(a.requestChannel, &message{
+		Type:   messageDisconnectSockets,
+		Rooms:  rooms,
+		Except: except,
+		Close:  close,
+	})
+}
+
+// ServerSideEmit sends an EVENT packet to every other server.
+func (a *Adapter) ServerSideEmit(packet *gosocketio.Packet) error {
+	msg := &message{Type: messageServerSideEmit}
+	if err := msg.setPacket(packet); err != nil {
+		return err
+	}
+
+	return a.publish(a.requestChannel, msg)
+}
+
+// ServerSideEmitWithAck sends an EVENT packet to every other server and reports
+// their acknowledgments until ctx is done.
+func (a *Adapter) ServerSideEmitWithAck(ctx context.Context, packet *gosocketio.Packet, onResponse func(args []interface{})) error {
+	msg := &message{
+		Type:      messageServerSideEmit,
+		RequestID: generateID(),
+	}
+	if err := msg.setPacket(packet); err != nil {
+		return err
+	}
+
+	a.addRequest(msg.RequestID, func(response *message) {
+		if ack, err := response.packet(); err == nil {
+			args, _ := ack.Data.([]interface{})
+			onResponse(args)
+		}
+	})
+
+	go func() {
+		<-ctx.Done()
+		a.removeRequest(msg.RequestID)
+	}()
+
+	return a.publish(a.requestChannel, msg)
+}
+
+// ServerCount returns the number of servers subscribed to the namespace,
+// including this one.
+func (a *Adapter) ServerCount() int {
+	ctx, cancel := context.WithTimeout(context.Background(), a.opts.RequestsTimeout)
+	defer cancel()
+
+	counts, err := a.client.PubSubNumSub(ctx, a.requestChannel).Result()
+	if err != nil || counts[a.requestChannel] < 1 {
+		return 1
+	}
+	return int(counts[a.requestChannel])
+}
+
+// Close unsubscribes from Redis and clears the local state.
+func (a *Adapter) Close() error {
+	err := a.pubsub.Close()
+	a.local.Close()
+	return err
+}
+
+func (a *Adapter) run() {
+	defer close(a.broadcasts)
+
+	for msg := range a.pubsub.Channel() {
+		var m message
+		if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+			continue
+		}
+
+		switch msg.Channel {
+		case a.broadcastChannel:
+			if m.UID != a.uid {
+				a.queueBroadcast(&m)
+			}
+		case a.requestChannel:
+			if m.UID != a.uid {
+				go a.onRequest(&m)
+			}
+		case a.responseChannel:
+			a.onResponse(&m)
+		}
+	}
+}
+
+// queueBroadcast queues a broadcast from another server for delivery. It is
+// dropped if the queue is full, so that the responses received meanwhile are
+// not held up behind it.
+func (a *Adapter) queueBroadcast(m *message) {
+	select {
+	case a.broadcasts <- m:
+	default:
+		if a.opts.OnOverflow == nil {
+			return
+		}
+		if packet, err := m.packet(); err == nil {
+			a.opts.OnOverflow(packet, m.Rooms)
+		}
+	}
+}
+
+// runBroadcasts delivers the broadcasts of other servers in order
+func (a *Adapter) runBroadcasts() {
+	for m := range a.broadcasts {
+		a.onBroadcast(m)
+	}
+}
+
+func (a *Adapter) onBroadcast(m *message) {
+	packet, err := m.packet()
+	if err != nil {
+		return
+	}
+
+	if m.RequestID == "" {
+		a.local.Broadcast(context.Background(), packet, m.Rooms, m.Except)
+		return
+	}
+
+	// Broadcast with acknowledgments: report targets and acks to the requester
+	timeout := time.Duration(m.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = a.opts.RequestsTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+	// Stop waiting once every target answered
+	var pending atomic.Int64
+	channel := a.responseChannelOf(m.UID)
+	err = a.local.BroadcastWithAck(ctx, packet, m.Rooms, m.Except,
+		func(socketIDs []string) {
+			a.publish(channel, &message{
+				Type:      messageBroadcastTargets,
+				RequestID: m.RequestID,
+				SocketIDs: socketIDs,
+			})
+			if pending.Add(int64(len(socketIDs))) == 0 {
+				cancel()
+			}
+		},
+		func(socketID string, args []interface{}, err error) {
+			defer func() {
+				if pending.Add(-1) == 0 {
+					cancel()
+				}
+			}()
+
+			response := &message{
+				Type:      messageBroadcastAck,
+				RequestID: m.RequestID,
+				SocketID:  socketID,
+			}
+			if err != nil {
+				response.Error = err.Error()
+				a.publish(channel, response)
+				return
+			}
+			if err := response.setPacket(&gosocketio.Packet{Type: gosocketio.PacketTypeAck, Data: args}); err == nil {
+				a.publish(channel, response)
+			}
+		})
+	if err != nil {
+		cancel()
+	}
+}
+
+func (a *Adapter) onRequest(m *message) {
+	response := &message{RequestID: m.RequestID}
+
+	switch m.Type {
+	case messageSocketsRequest:
+		response.Type = messageSocketsResponse
+		response.SocketIDs = a.local.Sockets(m.Room)
+	case messageSocketRoomsRequest:
+		response.Type = messageSocketRoomsResponse
+		response.Rooms = a.local.SocketRooms(m.SocketID)
+	case messageFetchSocketsRequest:
+		sockets, _ := a.local.FetchSockets(context.Background(), m.Rooms, m.Except)
+		response.Type = messageFetchSocketsResponse
+		response.Sockets = make([]remoteSocket, 0, len(sockets))
+		for _, socket := range sockets {
+			response.Sockets = append(response.Sockets, newRemoteSocket(socket))
+		}
+	case messageAddSockets:
+		a.local.AddSockets(m.Rooms, m.Except, m.Join)
+		return
+	case messageDelSockets:
+		a.local.DelSockets(m.Rooms, m.Except, m.Leave)
+		return
+	case messageDisconnectSockets:
+		a.local.DisconnectSockets(m.Rooms, m.Except, m.Close)
+		return
+	case messageServerSideEmit:
+		a.onServerSideEmit(m)
+		return
+	default:
+		return
+	}
+
+	a.publish(a.responseChannelOf(m.UID), response)
+}
+
+func (a *Adapter) onServerSideEmit(m *message) {
+	packet, err := m.packet()
+	if err != nil {
+		return
+	}
+
+	var ack func(args ...interface{})
+	if m.RequestID != "" {
+		channel := a.responseChannelOf(m.UID)
+		ack = func(args ...interface{}) {
+			response := &message{
+				Type:      messageServerSideEmitResponse,
+				RequestID: m.RequestID,
+			}
+			if err := response.setPacket(&gosocketio.Packet{Type: gosocketio.PacketTypeAck, Data: args}); err == nil {
+				a.publish(channel, response)
+			}
+		}
+	}
+
+	a.namespace.HandleServerSideEvent(packet, ack)
+}
+
+func (a *Adapter) onResponse(m *message) {
+	a.requestsMu.Lock()
+	handler, ok := a.requests[m.RequestID]
+	a.requestsMu.Unlock()
+
+	if ok {
+		handler(m)
+	}
+}
+
+// request publishes a request to the other servers and waits for their
+// responses. It returns the responses received so far along with an error if
+// ctx is done or the requests timeout expires first.
+func (a *Adapter) request(ctx context.Context, m *message) ([]*message, error) {
+	expected := a.ServerCount() - 1
+	if expected <= 0 {
+		return nil, nil
+	}
+
+	m.RequestID = generateID()
+	responses := make(chan *message, expected)
+
+	a.addRequest(m.RequestID, func(response *message) {
+		select {
+		case responses <- response:
+		default:
+		}
+	})
+	defer a.removeRequest(m.RequestID)
+
+	if err := a.publish(a.requestChannel, m); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.opts.RequestsTimeout)
+	defer cancel()
+
+	result := make([]*message, 0, expected)
+	for len(result) < expected {
+		select {
+		case response := <-responses:
+			result = append(result, response)
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+	return result, nil
+}
+
+func (a *Adapter) addRequest(id string, handler func(*message)) {
+	a.requestsMu.Lock()
+	a.requests[id] = handler
+	a.requestsMu.Unlock()
+}
+
+func (a *Adapter) removeRequest(id string) {
+	a.requestsMu.Lock()
+	delete(a.requests, id)
+	a.requestsMu.Unlock()
+}
+
+func (a *Adapter) publish(channel string, m *message) error {
+	m.UID = a.uid
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.opts.RequestsTimeout)
+	defer cancel()
+
+	return a.client.Publish(ctx, channel, payload).Err()
+}
+
+func (a *Adapter) responseChannelOf(uid string) string {
+	return a.opts.Key + "-response#" + a.namespace.Name() + "#" + uid + "#"
+}
+
+// timeoutOf returns the time left before the context deadline, or the
+// requests timeout if it has none
+func (a *Adapter) timeoutOf(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return time.Until(deadline)
+	}
+	return a.opts.RequestsTimeout
+}
+
+func generateID() string {
+	b := make([]byte, 15)
+	rand.Read(b)
+	return base64.URLEncoding.EncodeToString(b)
+}
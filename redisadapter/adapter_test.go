@@ -0,0 +1,310 @@
+package redisadapter_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/redisadapter"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+// newCluster starts two servers sharing an in-process Redis
+func newCluster(t *testing.T) (*siotest.Server, *siotest.Server) {
+	mr := miniredis.RunT(t)
+
+	newServer := func() *siotest.Server {
+		rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { rdb.Close() })
+
+		return siotest.NewServer(t, &gosocketio.Config{
+			AdapterFactory: redisadapter.Factory(rdb, &redisadapter.Options{RequestsTimeout: time.Second}),
+		})
+	}
+	return newServer(), newServer()
+}
+
+// eventually fails the test if cond does not hold within a second
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func inRoom(socket *gosocketio.Socket, room string) bool {
+	for _, r := range socket.Rooms() {
+		if r == room {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBroadcast(t *testing.T) {
+	server1, server2 := newCluster(t)
+	server2.OnConnect(func(socket *gosocketio.Socket) {
+		socket.Join("room")
+	})
+
+	alice := server1.Connect(t, "/")
+	bob := server2.Connect(t, "/")
+
+	if err := server1.Emit("hello", "everyone"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	for _, client := range []*siotest.Client{alice, bob} {
+		if event := client.ExpectEvent(t, "hello", time.Second); event.Args[0] != "everyone" {
+			t.Fatalf("got %v, want [everyone]", event.Args)
+		}
+	}
+
+	if err := server1.To("room").Emit("hello", "room"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	bob.ExpectEvent(t, "hello", time.Second)
+	alice.ExpectNoEvent(t, "hello", 100*time.Millisecond)
+}
+
+func TestBroadcastWithAck(t *testing.T) {
+	server1, server2 := newCluster(t)
+	alice := server1.Connect(t, "/")
+	bob := server2.Connect(t, "/")
+
+	done := make(chan struct{})
+	var (
+		result *gosocketio.BroadcastAckResult
+		err    error
+	)
+	go func() {
+		defer close(done)
+		result, err = server1.Timeout(time.Second).EmitWithAckContext(context.Background(), "ping")
+	}()
+
+	alice.ExpectEvent(t, "ping", time.Second).Ack("alice")
+	bob.ExpectEvent(t, "ping", time.Second).Ack("bob")
+	<-done
+
+	if err != nil {
+		t.Fatalf("EmitWithAckContext: %v", err)
+	}
+	if len(result.Responses) != 2 || result.Responses[bob.ID()][0] != "bob" {
+		t.Fatalf("got responses %v, missing %v", result.Responses, result.Missing)
+	}
+}
+
+func TestFetchSockets(t *testing.T) {
+	server1, server2 := newCluster(t)
+	server2.OnConnect(func(socket *gosocketio.Socket) {
+		socket.Set("user", "bob")
+		socket.Join("room")
+	})
+	alice := server1.Connect(t, "/")
+	bob := server2.Connect(t, "/")
+
+	sockets, err := server1.Of("/").FetchSockets(context.Background())
+	if err != nil {
+		t.Fatalf("FetchSockets: %v", err)
+	}
+
+	var ids []string
+	for _, socket := range sockets {
+		ids = append(ids, socket.ID)
+	}
+	want := []string{alice.ID(), bob.ID()}
+	sort.Strings(ids)
+	sort.Strings(want)
+	if len(ids) != 2 || ids[0] != want[0] || ids[1] != want[1] {
+		t.Fatalf("got sockets %v, want %v", ids, want)
+	}
+
+	// Remote sockets carry their rooms and data, and can be reached
+	sockets, err = server1.To("room").FetchSockets(context.Background())
+	if err != nil {
+		t.Fatalf("FetchSockets: %v", err)
+	}
+	if len(sockets) != 1 || sockets[0].ID != bob.ID() {
+		t.Fatalf("got %d sockets in the room, want bob", len(sockets))
+	}
+	remote := sockets[0]
+	if sort.Strings(remote.Rooms); len(remote.Rooms) != 2 || remote.Data["user"] != "bob" {
+		t.Fatalf("got rooms %v and data %v", remote.Rooms, remote.Data)
+	}
+	if remote.Handshake == nil || remote.Handshake.URL.Path != "/socket.io/" {
+		t.Fatalf("got handshake %+v", remote.Handshake)
+	}
+	if err := remote.Emit("hello", "bob"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	bob.ExpectEvent(t, "hello", time.Second)
+
+	sockets, err = server1.To("room").Except(bob.ID()).FetchSockets(context.Background())
+	if err != nil || len(sockets) != 0 {
+		t.Fatalf("FetchSockets = %d sockets, %v, want none", len(sockets), err)
+	}
+}
+
+func TestSocketsJoinAndLeave(t *testing.T) {
+	server1, server2 := newCluster(t)
+	server2.OnConnect(func(socket *gosocketio.Socket) {
+		if socket.Handshake().Auth["team"] == "red" {
+			socket.Join("red")
+		}
+	})
+	opts := server2.Options()
+	opts.Auth = map[string]interface{}{"team": "red"}
+	if _, err := server2.Dial("/", opts); err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	server2.Connect(t, "/")
+	alice := server1.Connect(t, "/")
+
+	var red, other *gosocketio.Socket
+	for _, socket := range server2.Of("/").Sockets() {
+		if inRoom(socket, "red") {
+			red = socket
+		} else {
+			other = socket
+		}
+	}
+	local := server1.Of("/").Sockets()[0]
+
+	if err := server1.Of("/").SocketsJoin("room"); err != nil {
+		t.Fatalf("SocketsJoin: %v", err)
+	}
+	eventually(t, func() bool { return inRoom(red, "room") && inRoom(other, "room") && inRoom(local, "room") })
+
+	if err := server1.Of("/").SocketsLeave("room"); err != nil {
+		t.Fatalf("SocketsLeave: %v", err)
+	}
+	eventually(t, func() bool { return !inRoom(red, "room") && !inRoom(other, "room") && !inRoom(local, "room") })
+
+	// Only the targeted sockets join, on every server
+	if err := server1.To("red").SocketsJoin("captains"); err != nil {
+		t.Fatalf("SocketsJoin: %v", err)
+	}
+	if err := server1.To("red", alice.ID()).Except(alice.ID()).SocketsJoin("remote"); err != nil {
+		t.Fatalf("SocketsJoin: %v", err)
+	}
+	eventually(t, func() bool { return inRoom(red, "captains") && inRoom(red, "remote") })
+	if inRoom(other, "captains") || inRoom(other, "remote") || inRoom(local, "captains") || inRoom(local, "remote") {
+		t.Fatalf("untargeted sockets joined: %v, %v", other.Rooms(), local.Rooms())
+	}
+}
+
+func TestDisconnectSockets(t *testing.T) {
+	server1, server2 := newCluster(t)
+	for _, server := range []*siotest.Server{server1, server2} {
+		server.OnConnect(func(socket *gosocketio.Socket) {
+			socket.On("ping", func(args ...interface{}) {
+				args[len(args)-1].(func(...interface{}))("pong")
+			})
+			if socket.Handshake().Auth["room"] == "kicked" {
+				socket.Join("kicked")
+			}
+		})
+	}
+
+	dial := func(server *siotest.Server, room string) *siotest.Client {
+		opts := server.Options()
+		opts.Auth = map[string]interface{}{"room": room}
+		client, err := server.Dial("/", opts)
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		return client
+	}
+	alice := dial(server1, "kicked")
+	bob := dial(server2, "kicked")
+	carol := dial(server2, "stays")
+
+	if err := server1.To("kicked").DisconnectSockets(false); err != nil {
+		t.Fatalf("DisconnectSockets: %v", err)
+	}
+	for _, client := range []*siotest.Client{alice, bob} {
+		if reason := client.ExpectDisconnect(t, time.Second); reason != "io server disconnect" {
+			t.Fatalf("disconnected with %q, want io server disconnect", reason)
+		}
+	}
+	eventually(t, func() bool { return len(server2.Of("/").Sockets()) == 1 })
+	if response := carol.ExpectAck(t, time.Second, "ping"); len(response) != 1 || response[0] != "pong" {
+		t.Fatalf("got %v, want carol still connected", response)
+	}
+}
+
+func TestServerSideEmit(t *testing.T) {
+	server1, server2 := newCluster(t)
+
+	type received struct {
+		args    []interface{}
+		withAck bool
+	}
+	events := make(chan received, 4)
+	for _, server := range []*siotest.Server{server1, server2} {
+		server.Of("/").OnServerSideEvent("invalidate", func(args ...interface{}) {
+			_, withAck := args[len(args)-1].(func(...interface{}))
+			events <- received{args, withAck}
+		})
+	}
+
+	if err := server1.Of("/").ServerSideEmit("invalidate", "user:42", 7); err != nil {
+		t.Fatalf("ServerSideEmit: %v", err)
+	}
+	select {
+	case e := <-events:
+		if e.withAck || len(e.args) != 2 || e.args[0] != "user:42" || e.args[1] != float64(7) {
+			t.Fatalf("got %v, want the arguments without an ack", e.args)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server-side event not received")
+	}
+
+	// The event is not passed to the handlers of the sending server
+	select {
+	case e := <-events:
+		t.Fatalf("event received twice: %v", e.args)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestServerSideEmitWithAck(t *testing.T) {
+	server1, server2 := newCluster(t)
+
+	server2.Of("/").OnServerSideEvent("hello", func(args ...interface{}) {
+		ack := args[len(args)-1].(func(...interface{}))
+		ack("hi "+args[0].(string), 2)
+		ack("ignored")
+	})
+	server2.Of("/").OnServerSideEvent("ignored", func(args ...interface{}) {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	responses, err := server1.Of("/").ServerSideEmitWithAck(ctx, "hello", "server1")
+	if err != nil {
+		t.Fatalf("ServerSideEmitWithAck: %v", err)
+	}
+	if len(responses) != 1 || len(responses[0]) != 2 || responses[0][0] != "hi server1" || responses[0][1] != float64(2) {
+		t.Fatalf("got responses %v, want one per server", responses)
+	}
+
+	// Servers that do not acknowledge make the call time out
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	responses, err = server1.Of("/").ServerSideEmitWithAck(ctx, "ignored")
+	if !errors.Is(err, context.DeadlineExceeded) || len(responses) != 0 {
+		t.Fatalf("ServerSideEmitWithAck = %v, %v, want a timeout without responses", responses, err)
+	}
+}
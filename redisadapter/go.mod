@@ -0,0 +1,19 @@
+module github.com/ramory-l/gosocketio/redisadapter
+
+go 1.26
+
+require (
+	github.com/alicebob/miniredis/v2 v2.37.0
+	github.com/ramory-l/gosocketio v0.0.0-00010101000000-000000000000
+	github.com/redis/go-redis/v9 v9.7.3
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/gorilla/websocket v1.5.1 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/net v0.17.0 // indirect
+)
+
+replace github.com/ramory-l/gosocketio => ../
@@ -0,0 +1,130 @@
+package redisadapter
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+)
+
+// messageType identifies the messages exchanged between servers
+type messageType int
+
+const (
+	messageBroadcast messageType = iota
+	messageBroadcastTargets
+	messageBroadcastAck
+	messageSocketsRequest
+	messageSocketsResponse
+	messageSocketRoomsRequest
+	messageSocketRoomsResponse
+	messageFetchSocketsRequest
+	messageFetchSocketsResponse
+	messageAddSockets
+	messageDelSockets
+	messageDisconnectSockets
+	messageServerSideEmit
+	messageServerSideEmitResponse
+)
+
+// message is the JSON payload published on the Redis channels
+type message struct {
+	UID       string      `json:"uid"`
+	Type      messageType `json:"type"`
+	RequestID string      `json:"requestId,omitempty"`
+
+	// Encoded Socket.IO packet and its binary attachments
+	Packet      string   `json:"packet,omitempty"`
+	Attachments [][]byte `json:"attachments,omitempty"`
+
+	// Broadcast targets
+	Rooms   []string `json:"rooms,omitempty"`
+	Except  []string `json:"except,omitempty"`
+	Timeout int64    `json:"timeout,omitempty"` // milliseconds
+
+	// Request arguments and response data
+	Room      string   `json:"room,omitempty"`
+	SocketID  string   `json:"socketId,omitempty"`
+	SocketIDs []string `json:"socketIds,omitempty"`
+	Join      []string `json:"join,omitempty"`
+	Leave     []string `json:"leave,omitempty"`
+	Close     bool     `json:"close,omitempty"`
+	Error     string   `json:"error,omitempty"`
+
+	Sockets []remoteSocket `json:"sockets,omitempty"`
+}
+
+// remoteSocket is the JSON form of a gosocketio.RemoteSocket
+type remoteSocket struct {
+	ID        string                 `json:"id"`
+	Handshake handshake              `json:"handshake"`
+	Rooms     []string               `json:"rooms"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// handshake is the JSON form of a gosocketio.Handshake, without its TLS state
+type handshake struct {
+	Headers http.Header            `json:"headers,omitempty"`
+	Query   url.Values             `json:"query,omitempty"`
+	Address string                 `json:"address"`
+	URL     string                 `json:"url"`
+	Time    time.Time              `json:"time"`
+	Auth    map[string]interface{} `json:"auth,omitempty"`
+}
+
+func newRemoteSocket(socket *gosocketio.RemoteSocket) remoteSocket {
+	result := remoteSocket{
+		ID:    socket.ID,
+		Rooms: socket.Rooms,
+		Data:  socket.Data,
+	}
+
+	if h := socket.Handshake; h != nil {
+		result.Handshake = handshake{
+			Headers: h.Headers,
+			Query:   h.Query,
+			Address: h.Address,
+			Time:    h.Time,
+			Auth:    h.Auth,
+		}
+		if h.URL != nil {
+			result.Handshake.URL = h.URL.String()
+		}
+	}
+
+	return result
+}
+
+func (s *remoteSocket) remoteSocket() *gosocketio.RemoteSocket {
+	h := &gosocketio.Handshake{
+		Headers: s.Handshake.Headers,
+		Query:   s.Handshake.Query,
+		Address: s.Handshake.Address,
+		Time:    s.Handshake.Time,
+		Auth:    s.Handshake.Auth,
+	}
+	h.URL, _ = url.Parse(s.Handshake.URL)
+
+	return &gosocketio.RemoteSocket{
+		ID:        s.ID,
+		Handshake: h,
+		Rooms:     s.Rooms,
+		Data:      s.Data,
+	}
+}
+
+func (m *message) setPacket(packet *gosocketio.Packet) error {
+	encoded, attachments, err := packet.EncodeWithAttachments()
+	if err != nil {
+		return err
+	}
+
+	m.Packet = encoded
+	m.Attachments = attachments
+	return nil
+}
+
+func (m *message) packet() (*gosocketio.Packet, error) {
+	return gosocketio.DecodePacketWithAttachments(m.Packet, m.Attachments)
+}
@@ -0,0 +1,54 @@
+package redisadapter
+
+import (
+	"testing"
+
+	"github.com/ramory-l/gosocketio"
+)
+
+func TestQueueBroadcastDropsWhenFull(t *testing.T) {
+	type overflow struct {
+		packet *gosocketio.Packet
+		rooms  []string
+	}
+	overflows := make(chan overflow, 1)
+	a := &Adapter{
+		broadcasts: make(chan *message, 1),
+		opts: Options{OnOverflow: func(packet *gosocketio.Packet, rooms []string) {
+			overflows <- overflow{packet, rooms}
+		}},
+	}
+
+	newBroadcast := func(data string) *message {
+		m := &message{Type: messageBroadcast, Rooms: []string{"room"}}
+		if err := m.setPacket(&gosocketio.Packet{Type: gosocketio.PacketTypeEvent, Namespace: "/", Data: []interface{}{"news", data}}); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	queued := newBroadcast("first")
+	a.queueBroadcast(queued)
+	select {
+	case o := <-overflows:
+		t.Fatalf("first broadcast dropped: %+v", o)
+	default:
+	}
+
+	// The receive loop must not wait for the queue to drain
+	a.queueBroadcast(newBroadcast("second"))
+	select {
+	case o := <-overflows:
+		data, _ := o.packet.DecodedData()
+		args, _ := data.([]interface{})
+		if len(args) != 2 || args[1] != "second" || len(o.rooms) != 1 || o.rooms[0] != "room" {
+			t.Fatalf("OnOverflow got %v to %v, want the second broadcast", data, o.rooms)
+		}
+	default:
+		t.Fatal("OnOverflow not called for the dropped broadcast")
+	}
+
+	if m := <-a.broadcasts; m != queued {
+		t.Fatalf("queued %+v, want the first broadcast", m)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ramory-l/gosocketio/engineio"
 )
@@ -21,8 +22,13 @@ import (
 //	http.ListenAndServe(":3000", nil)
 type Server struct {
 	eio        *engineio.Server
+	config     Config
 	namespaces map[string]*Namespace
+	parents    []*ParentNamespace
 	nsMu       sync.RWMutex
+	pool       *workerPool
+	onError    ErrorHandler
+	errorMu    sync.RWMutex
 }
 
 // Config represents Socket.IO server configuration options.
@@ -33,6 +39,123 @@ type Config struct {
 	PingInterval int // Interval between ping packets in milliseconds (default: 25000)
 	PingTimeout  int // Timeout for ping response in milliseconds (default: 20000)
 	MaxPayload   int // Maximum payload size in bytes (default: 1000000)
+
+	// ConnectTimeout is how long a client may stay connected without joining
+	// any namespace, in milliseconds (default: 45000)
+	ConnectTimeout int
+
+	// AllowedOrigins lists the cross-origin browsers allowed to connect: exact
+	// origins ("https://example.com"), wildcard subdomains
+	// ("https://*.example.com", or "https://*.example.com:*" for any port)
+	// or "*" for any origin. Same-origin requests and non-browser clients
+	// are always allowed; other origins are rejected unless listed here or
+	// allowed by CheckOrigin.
+	AllowedOrigins []string
+
+	// CheckOrigin is called for origins not matched by AllowedOrigins and
+	// reports whether the request is allowed.
+	CheckOrigin func(r *http.Request) bool
+
+	// AllowCredentials allows browsers to send cookies with cross-origin
+	// requests from origins matched explicitly, by an AllowedOrigins entry
+	// other than "*" or by CheckOrigin.
+	AllowCredentials bool
+
+	// AllowedHeaders lists the request headers allowed in cross-origin requests.
+	AllowedHeaders []string
+
+	// CORSMaxAge is how long browsers may cache preflight results, in seconds.
+	CORSMaxAge int
+
+	// ConnectionStateRecovery enables restoring the socket ID, rooms, data and
+	// missed broadcasts of clients that reconnect shortly after losing their
+	// connection. Recovery is disabled if nil.
+	ConnectionStateRecovery *RecoveryConfig
+
+	// AdapterFactory creates the adapter of every namespace. If nil, each
+	// namespace uses a MemoryAdapter.
+	//
+	// Example:
+	//
+	//	config := &gosocketio.Config{
+	//	    AdapterFactory: redisadapter.Factory(redisClient, nil),
+	//	}
+	AdapterFactory func(ns *Namespace) Adapter
+
+	// Parser encodes and decodes packets (default: JSONParser). Clients must
+	// use a compatible parser.
+	Parser Parser
+
+	// MaxAttachments is the maximum number of binary attachments of a packet
+	// received with the default parser (default: DefaultMaxAttachments).
+	// Packets declaring more are dropped, so that a client cannot make the
+	// server buffer binary frames without bound. See JSONParser.
+	MaxAttachments int
+
+	// DispatchMode selects how event handlers are run (default: DispatchConcurrent).
+	//
+	// With the ordered modes and DispatchPool, events are queued and reading
+	// from a connection blocks while its queue is full. Handlers must then not
+	// wait for an acknowledgment from the client that sent the event, as it
+	// cannot be read until they return.
+	DispatchMode DispatchMode
+
+	// DispatchQueueSize is the number of events queued per socket with the
+	// ordered modes, or for the whole server with DispatchPool (default: 100)
+	DispatchQueueSize int
+
+	// DispatchWorkers is the number of goroutines running handlers with
+	// DispatchPool (default: number of CPUs)
+	DispatchWorkers int
+
+	// AckOnPanic acknowledges events whose handler panicked before answering
+	// with {"error": "internal server error"}, instead of leaving the client
+	// waiting for an acknowledgment.
+	AckOnPanic bool
+
+	// CleanupEmptyChildNamespaces removes child namespaces created by a
+	// ParentNamespace once their last socket disconnects.
+	CleanupEmptyChildNamespaces bool
+
+	// AllowRequest is called for every handshake request before a connection is
+	// accepted or upgraded. Returning an error rejects the request with a
+	// Forbidden error, which is cheaper than rejecting it in a namespace middleware.
+	AllowRequest func(r *http.Request) error
+
+	// Clock schedules ping, connect and Socket.Timeout acknowledgment
+	// timeouts and timestamps recovered sessions. If nil, the time package is used. Tests can set a fake clock
+	// such as siotest.Clock to control time.
+	Clock engineio.Clock
+
+	// AllowEIO3 accepts socket.io-client 2.x clients, which speak Socket.IO
+	// v2 over Engine.IO v3, alongside v4 clients. They are connected to the
+	// main namespace on handshake, and the query they append to other
+	// namespaces is used as their auth. Connection state recovery is not
+	// available to them.
+	AllowEIO3 bool
+
+	// MaxQueuedPackets is the number of packets queued for a slow client
+	// before OverflowPolicy applies (default: 256). A packet with binary
+	// attachments counts as one packet plus one per attachment.
+	MaxQueuedPackets int
+
+	// MaxQueuedBytes is the size of the packets queued for a slow client
+	// before OverflowPolicy applies. The queue size is unlimited if zero.
+	MaxQueuedBytes int
+
+	// OverflowPolicy selects what happens to packets sent to a client whose
+	// queue is full: rejecting them with engineio.ErrSlowClient (default),
+	// dropping the oldest queued packets, blocking the sender, or
+	// disconnecting the client with reason "slow client".
+	//
+	// With engineio.OverflowBlock, Emit and broadcasts wait until the client
+	// catches up or disconnects; use Socket.EmitContext and
+	// BroadcastOperator.EmitContext to bound the wait.
+	OverflowPolicy engineio.OverflowPolicy
+
+	// OnOverflow is called with the encoded packets being sent whenever the
+	// queue of a client is full, to count or log lost data. It must not block.
+	OnOverflow func(session *engineio.Session, packets []*engineio.Packet)
 }
 
 // NewServer creates a new Socket.IO server with the given configuration.
@@ -41,6 +164,7 @@ type Config struct {
 //   - PingInterval: 25000ms (25 seconds)
 //   - PingTimeout: 20000ms (20 seconds)
 //   - MaxPayload: 1000000 bytes (1MB)
+//   - ConnectTimeout: 45000ms (45 seconds)
 //
 // The server automatically creates a default namespace ("/") and is ready
 // to accept connections immediately after creation.
@@ -61,9 +185,21 @@ func NewServer(config *Config) *Server {
 	var eioConfig *engineio.Config
 	if config != nil {
 		eioConfig = &engineio.Config{
-			PingInterval: config.PingInterval,
-			PingTimeout:  config.PingTimeout,
-			MaxPayload:   config.MaxPayload,
+			PingInterval:     config.PingInterval,
+			PingTimeout:      config.PingTimeout,
+			MaxPayload:       config.MaxPayload,
+			AllowedOrigins:   config.AllowedOrigins,
+			CheckOrigin:      config.CheckOrigin,
+			AllowCredentials: config.AllowCredentials,
+			AllowedHeaders:   config.AllowedHeaders,
+			CORSMaxAge:       config.CORSMaxAge,
+			AllowRequest:     config.AllowRequest,
+			Clock:            config.Clock,
+			AllowEIO3:        config.AllowEIO3,
+			MaxQueuedPackets: config.MaxQueuedPackets,
+			MaxQueuedBytes:   config.MaxQueuedBytes,
+			OverflowPolicy:   config.OverflowPolicy,
+			OnOverflow:       config.OnOverflow,
 		}
 	}
 
@@ -72,6 +208,19 @@ func NewServer(config *Config) *Server {
 		namespaces: make(map[string]*Namespace),
 	}
 
+	if config != nil {
+		server.config = *config
+	}
+	if server.config.ConnectTimeout <= 0 {
+		server.config.ConnectTimeout = 45000
+	}
+	if server.config.DispatchQueueSize <= 0 {
+		server.config.DispatchQueueSize = 100
+	}
+	if server.config.DispatchMode == DispatchPool {
+		server.pool = newWorkerPool(server.config.DispatchWorkers, server.config.DispatchQueueSize)
+	}
+
 	// Create default namespace
 	server.Of("/")
 
@@ -170,6 +319,18 @@ func (s *Server) To(rooms ...string) *BroadcastOperator {
 	return s.Of("/").To(rooms...)
 }
 
+// Timeout returns a BroadcastOperator for the default namespace whose
+// acknowledgments expire after d.
+//
+// This is a convenience method equivalent to calling server.Of("/").Timeout(d).
+//
+// Example:
+//
+//	result, err := server.Timeout(5*time.Second).EmitWithAckContext(ctx, "still_there")
+func (s *Server) Timeout(d time.Duration) *BroadcastOperator {
+	return s.Of("/").Timeout(d)
+}
+
 // ServeHTTP implements http.Handler, allowing the server to be used with the standard library's HTTP server.
 //
 // The server expects to be mounted at "/socket.io/" path. Requests to other paths will return 404.
@@ -193,7 +354,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // Close gracefully closes the server and all active connections.
 //
-// This method closes all namespaces and their associated resources.
+// This method closes all namespaces and their associated resources, and
+// stops the goroutines running handlers with DispatchPool.
 // It is recommended to call this method during application shutdown.
 //
 // Example:
@@ -202,6 +364,10 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (s *Server) Close() error {
 	s.eio.Close()
 
+	if s.pool != nil {
+		s.pool.close()
+	}
+
 	s.nsMu.RLock()
 	defer s.nsMu.RUnlock()
 
@@ -212,8 +378,28 @@ func (s *Server) Close() error {
 	return nil
 }
 
+// reserveNamespace returns an existing namespace without creating it, and
+// counts a pending connection to it until Namespace.admit is called
+func (s *Server) reserveNamespace(name string) (*Namespace, bool) {
+	s.nsMu.RLock()
+	defer s.nsMu.RUnlock()
+
+	ns, ok := s.namespaces[name]
+	if ok {
+		ns.reserve()
+	}
+	return ns, ok
+}
+
 func (s *Server) handleConnection(session *engineio.Session) {
-	// Default to root namespace
-	ns := s.Of("/")
-	ns.addSocket(session)
+	// The client joins namespaces by sending CONNECT packets
+	newClient(s, session)
+}
+
+// clock returns the clock configured for the server
+func (s *Server) clock() engineio.Clock {
+	if s == nil || s.config.Clock == nil {
+		return engineio.RealClock
+	}
+	return s.config.Clock
 }
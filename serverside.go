@@ -0,0 +1,175 @@
+package gosocketio
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrServerSideEmitNotSupported is returned by Namespace.ServerSideEmit when
+// the namespace adapter does not implement ServerSideEmitter.
+var ErrServerSideEmitNotSupported = errors.New("adapter does not support server-side emit")
+
+// ServerSideEmitter is an optional interface for adapters that can send
+// events to the other servers of the cluster. MemoryAdapter implements it as
+// a single-server cluster, where there is no other server to reach.
+//
+// The receiving servers pass the packets to Namespace.HandleServerSideEvent.
+type ServerSideEmitter interface {
+	// ServerSideEmit sends an EVENT packet to every other server.
+	ServerSideEmit(packet *Packet) error
+
+	// ServerSideEmitWithAck sends an EVENT packet to every other server and
+	// calls onResponse with the acknowledgment of each of them until ctx is
+	// done.
+	ServerSideEmitWithAck(ctx context.Context, packet *Packet, onResponse func(args []interface{})) error
+
+	// ServerCount returns the number of servers in the cluster, including
+	// this one.
+	ServerCount() int
+}
+
+// OnServerSideEvent registers a handler for events sent by other servers with
+// ServerSideEmit.
+//
+// If the sending server expects an acknowledgment, the last argument is a
+// function to call with the response. Only the first response is sent back.
+//
+// Example:
+//
+//	ns.OnServerSideEvent("invalidate", func(args ...interface{}) {
+//	    cache.Delete(args[0].(string))
+//	})
+func (ns *Namespace) OnServerSideEvent(event string, handler EventHandler) {
+	ns.serverSideHandlersMu.Lock()
+	ns.serverSideHandlers[event] = append(ns.serverSideHandlers[event], handler)
+	ns.serverSideHandlersMu.Unlock()
+}
+
+// ServerSideEmit sends an event to the other servers of the cluster, where it
+// is passed to the handlers registered with OnServerSideEvent. The event is
+// not delivered to this server.
+//
+// Example:
+//
+//	ns.ServerSideEmit("invalidate", "user:42")
+func (ns *Namespace) ServerSideEmit(event string, data ...interface{}) error {
+	adapter, ok := ns.adapter.(ServerSideEmitter)
+	if !ok {
+		return ErrServerSideEmitNotSupported
+	}
+
+	return adapter.ServerSideEmit(ns.serverSidePacket(event, data))
+}
+
+// ServerSideEmitWithAck sends an event to the other servers of the cluster and
+// waits until every one of them has acknowledged it or ctx is done.
+//
+// It returns the responses received, one per server. If some servers did not
+// answer, the responses received so far are returned along with the context
+// error. Use context.WithTimeout to bound the wait.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	responses, err := ns.ServerSideEmitWithAck(ctx, "connected_users")
+func (ns *Namespace) ServerSideEmitWithAck(ctx context.Context, event string, data ...interface{}) ([][]interface{}, error) {
+	adapter, ok := ns.adapter.(ServerSideEmitter)
+	if !ok {
+		return nil, ErrServerSideEmitNotSupported
+	}
+
+	expected := adapter.ServerCount() - 1
+	if expected <= 0 {
+		return [][]interface{}{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	responses := make([][]interface{}, 0, expected)
+	done := make(chan struct{})
+
+	err := adapter.ServerSideEmitWithAck(ctx, ns.serverSidePacket(event, data), func(args []interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(responses) == expected {
+			return
+		}
+		responses = append(responses, args)
+		if len(responses) == expected {
+			close(done)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(responses) == expected {
+		err = nil
+	}
+	return append([][]interface{}(nil), responses...), err
+}
+
+// HandleServerSideEvent passes an EVENT packet sent by another server to the
+// handlers registered with OnServerSideEvent. ack is nil if the sending server
+// does not expect an acknowledgment.
+//
+// It is meant for adapters implementing ServerSideEmitter.
+func (ns *Namespace) HandleServerSideEvent(packet *Packet, ack func(args ...interface{})) {
+	dataArray := packet.decodedArgs()
+	if len(dataArray) == 0 {
+		return
+	}
+
+	event, ok := dataArray[0].(string)
+	if !ok {
+		return
+	}
+
+	args := dataArray[1:]
+
+	if ack != nil {
+		var once sync.Once
+		ackFunc := func(ackData ...interface{}) {
+			once.Do(func() { ack(ackData...) })
+		}
+		args = append(args, ackFunc)
+	}
+
+	ns.serverSideHandlersMu.RLock()
+	handlers := ns.serverSideHandlers[event]
+	ns.serverSideHandlersMu.RUnlock()
+
+	for _, handler := range handlers {
+		handler := handler
+		go func() {
+			defer ns.server.recoverPanic(nil, event)
+			handler(args...)
+		}()
+	}
+}
+
+func (ns *Namespace) serverSidePacket(event string, data []interface{}) *Packet {
+	args := make([]interface{}, 0, len(data)+1)
+	args = append(args, event)
+	args = append(args, data...)
+
+	return &Packet{
+		Type:      PacketTypeEvent,
+		Namespace: ns.name,
+		Data:      args,
+	}
+}
@@ -0,0 +1,51 @@
+package gosocketio_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+func TestServerSideEmitSingleServer(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	ns := server.Of("/")
+	events := make(chan []interface{}, 1)
+	ns.OnServerSideEvent("invalidate", func(args ...interface{}) { events <- args })
+
+	// The memory adapter is a cluster of one server: there is nobody to reach
+	if err := ns.ServerSideEmit("invalidate", "user:42"); err != nil {
+		t.Fatalf("ServerSideEmit: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	responses, err := ns.ServerSideEmitWithAck(ctx, "invalidate", "user:42")
+	if err != nil || responses == nil || len(responses) != 0 {
+		t.Fatalf("ServerSideEmitWithAck = %v, %v, want no responses", responses, err)
+	}
+
+	select {
+	case args := <-events:
+		t.Fatalf("event passed to the local handlers: %v", args)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Events from other servers still reach the handlers
+	ns.HandleServerSideEvent(&gosocketio.Packet{
+		Type:      gosocketio.PacketTypeEvent,
+		Namespace: "/",
+		Data:      []interface{}{"invalidate", "user:7"},
+	}, nil)
+	select {
+	case args := <-events:
+		if len(args) != 1 || args[0] != "user:7" {
+			t.Fatalf("got %v, want [user:7]", args)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server-side event not handled")
+	}
+}
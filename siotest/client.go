@@ -0,0 +1,198 @@
+package siotest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio/client"
+)
+
+// Client is a client socket that records the events it receives and the
+// reasons it disconnects.
+type Client struct {
+	*client.Socket
+
+	partitioned *atomic.Bool
+
+	mu          sync.Mutex
+	events      []*Event
+	disconnects []string
+
+	// changed is closed and replaced whenever something is recorded
+	changed chan struct{}
+}
+
+// Event is an event received by a Client.
+type Event struct {
+	// Name is the event name.
+	Name string
+
+	// Args are the event arguments, without the acknowledgment function.
+	Args []interface{}
+
+	ack func(...interface{})
+}
+
+// Ack acknowledges the event with the arguments. It reports whether the
+// server requested an acknowledgment.
+func (e *Event) Ack(args ...interface{}) bool {
+	if e.ack == nil {
+		return false
+	}
+	e.ack(args...)
+	return true
+}
+
+func newClient(socket *client.Socket, partitioned *atomic.Bool) *Client {
+	c := &Client{
+		Socket:      socket,
+		partitioned: partitioned,
+		changed:     make(chan struct{}),
+	}
+
+	socket.OnAny(func(event string, args ...interface{}) {
+		e := &Event{Name: event, Args: args}
+		if len(args) > 0 {
+			if ack, ok := args[len(args)-1].(func(...interface{})); ok {
+				e.Args = args[:len(args)-1]
+				e.ack = ack
+			}
+		}
+
+		c.mu.Lock()
+		c.events = append(c.events, e)
+		c.notifyLocked()
+		c.mu.Unlock()
+	})
+
+	socket.OnDisconnect(func(reason string) {
+		c.mu.Lock()
+		c.disconnects = append(c.disconnects, reason)
+		c.notifyLocked()
+		c.mu.Unlock()
+	})
+
+	return c
+}
+
+// Partition drops everything the client sends from now on, as if the network
+// lost it, including its answers to pings. The client still receives packets,
+// so the server times it out once its clock is advanced past the ping
+// interval and timeout.
+func (c *Client) Partition() {
+	c.partitioned.Store(true)
+}
+
+// notifyLocked wakes up waiting expectations. The caller must hold c.mu.
+func (c *Client) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// ExpectEvent waits for the next event with the name and removes it from the
+// received events. It fails the test if none is received within timeout.
+func (c *Client) ExpectEvent(t testing.TB, name string, timeout time.Duration) *Event {
+	t.Helper()
+
+	var event *Event
+	ok := c.wait(timeout, func() bool {
+		event = c.takeEvent(name)
+		return event != nil
+	})
+	if !ok {
+		t.Fatalf("siotest: no %q event received within %v", name, timeout)
+	}
+	return event
+}
+
+// ExpectNoEvent fails the test if an event with the name is received within d.
+func (c *Client) ExpectNoEvent(t testing.TB, name string, d time.Duration) {
+	t.Helper()
+
+	var event *Event
+	if c.wait(d, func() bool {
+		event = c.takeEvent(name)
+		return event != nil
+	}) {
+		t.Fatalf("siotest: unexpected %q event with %v", name, event.Args)
+	}
+}
+
+// ExpectAck emits an event and waits for its acknowledgment, failing the test
+// if none is received within timeout.
+func (c *Client) ExpectAck(t testing.TB, timeout time.Duration, event string, data ...interface{}) []interface{} {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	response, err := c.EmitWithAckContext(ctx, event, data...)
+	if err != nil {
+		t.Fatalf("siotest: no acknowledgment for %q: %v", event, err)
+	}
+	return response
+}
+
+// ExpectDisconnect waits for the client to disconnect and returns the reason.
+// It fails the test if the client is still connected after timeout.
+func (c *Client) ExpectDisconnect(t testing.TB, timeout time.Duration) string {
+	t.Helper()
+
+	var reason string
+	ok := c.wait(timeout, func() bool {
+		if len(c.disconnects) == 0 {
+			return false
+		}
+		reason = c.disconnects[0]
+		c.disconnects = c.disconnects[1:]
+		return true
+	})
+	if !ok {
+		t.Fatalf("siotest: client not disconnected within %v", timeout)
+	}
+	return reason
+}
+
+// Events returns the received events not yet taken by ExpectEvent.
+func (c *Client) Events() []*Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Event(nil), c.events...)
+}
+
+// takeEvent removes and returns the first event with the name. The caller
+// must hold c.mu.
+func (c *Client) takeEvent(name string) *Event {
+	for i, event := range c.events {
+		if event.Name == name {
+			c.events = append(c.events[:i:i], c.events[i+1:]...)
+			return event
+		}
+	}
+	return nil
+}
+
+// wait calls done with c.mu held until it returns true or timeout elapses
+func (c *Client) wait(timeout time.Duration, done func() bool) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		if done() {
+			c.mu.Unlock()
+			return true
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		}
+	}
+}
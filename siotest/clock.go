@@ -0,0 +1,102 @@
+package siotest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ramory-l/gosocketio/engineio"
+)
+
+// Clock is a fake engineio.Clock whose time only moves when advanced. Timers
+// fire synchronously in Advance, so their effects, such as a ping being
+// queued or a session closing, are visible once it returns.
+//
+// Clients answer pings asynchronously: after a ping, advance by less than the
+// ping timeout until the client has had time to answer, or use
+// Client.Partition to simulate a client that never answers.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+}
+
+// NewClock returns a fake clock set to the current time.
+func NewClock() *Clock {
+	return &Clock{now: time.Now()}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc calls f once the clock has been advanced by d.
+func (c *Clock) AfterFunc(d time.Duration, f func()) engineio.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &timer{clock: c, when: c.now.Add(d), fn: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers that expire in
+// order, including timers created by the ones fired.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		next := c.next(target)
+		if next == nil {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		c.now = next.when
+		c.remove(next)
+		c.mu.Unlock()
+
+		next.fn()
+	}
+}
+
+// next returns the earliest timer expiring by target. The caller must hold c.mu.
+func (c *Clock) next(target time.Time) *timer {
+	var next *timer
+	for _, t := range c.timers {
+		if !t.when.After(target) && (next == nil || t.when.Before(next.when)) {
+			next = t
+		}
+	}
+	return next
+}
+
+// remove removes a pending timer and reports whether it was pending. The
+// caller must hold c.mu.
+func (c *Clock) remove(t *timer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type timer struct {
+	clock *Clock
+	when  time.Time
+	fn    func()
+}
+
+// Stop implements engineio.Timer
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
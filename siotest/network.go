@@ -0,0 +1,79 @@
+package siotest
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// listener is an in-memory net.Listener whose connections are created by
+// DialContext with net.Pipe
+type listener struct {
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newListener() *listener {
+	return &listener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// Accept implements net.Listener
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+// Addr implements net.Listener
+func (l *listener) Addr() net.Addr {
+	return addr{}
+}
+
+// DialContext connects to the listener, ignoring the network and address
+func (l *listener) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type addr struct{}
+
+func (addr) Network() string { return "memory" }
+func (addr) String() string  { return "siotest" }
+
+// partitionConn is a client connection whose writes are dropped once
+// partitioned is set, as if the network lost them
+type partitionConn struct {
+	net.Conn
+	partitioned *atomic.Bool
+}
+
+func (c *partitionConn) Write(p []byte) (int, error) {
+	if c.partitioned.Load() {
+		return len(p), nil
+	}
+	return c.Conn.Write(p)
+}
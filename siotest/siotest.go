@@ -0,0 +1,176 @@
+// Package siotest provides an in-memory harness for testing Socket.IO
+// handlers.
+//
+// NewServer creates a gosocketio.Server served over an in-memory network,
+// without listening on a port, and with a fake Clock driving its ping timers.
+// Clients connected with Server.Connect are real Socket.IO clients from the
+// client package, which record the events they receive so that tests can
+// assert on them.
+//
+// Example:
+//
+//	func TestChat(t *testing.T) {
+//	    server := siotest.NewServer(t, nil)
+//	    server.OnConnect(func(socket *gosocketio.Socket) {
+//	        socket.On("join", func(data ...interface{}) {
+//	            socket.Join(data[0].(string))
+//	            socket.Emit("joined", data[0])
+//	        })
+//	    })
+//
+//	    alice := server.Connect(t, "/")
+//	    alice.Emit("join", "lobby")
+//	    event := alice.ExpectEvent(t, "joined", time.Second)
+//	    if event.Args[0] != "lobby" {
+//	        t.Fatalf("joined %v", event.Args[0])
+//	    }
+//
+//	    server.Clock.Advance(25 * time.Second) // the server pings every client
+//	}
+package siotest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/client"
+	"github.com/ramory-l/gosocketio/engineio"
+)
+
+// ConnectTimeout is how long Server.Connect waits for a client to connect.
+var ConnectTimeout = 5 * time.Second
+
+// Server is a gosocketio.Server served over an in-memory network.
+type Server struct {
+	*gosocketio.Server
+
+	// Clock drives the ping and connect timers of the server. It is nil if
+	// the config passed to NewServer had another clock.
+	Clock *Clock
+
+	listener *listener
+	http     *http.Server
+
+	mu       sync.Mutex
+	managers []*client.Manager
+}
+
+// NewServer creates a server with the config, using a fake Clock unless the
+// config has one. The server and its clients are closed when the test ends.
+func NewServer(t testing.TB, config *gosocketio.Config) *Server {
+	var cfg gosocketio.Config
+	if config != nil {
+		cfg = *config
+	}
+
+	clock, _ := cfg.Clock.(*Clock)
+	if cfg.Clock == nil {
+		clock = NewClock()
+		cfg.Clock = clock
+	}
+
+	s := &Server{
+		Server:   gosocketio.NewServer(&cfg),
+		Clock:    clock,
+		listener: newListener(),
+	}
+	s.http = &http.Server{Handler: s.Server}
+	go s.http.Serve(s.listener)
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// Close closes the clients, then the server.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	managers := s.managers
+	s.managers = nil
+	s.mu.Unlock()
+
+	for _, manager := range managers {
+		manager.Close()
+	}
+
+	s.Server.Close()
+	return s.http.Close()
+}
+
+// Options returns client options connecting over the in-memory network, over
+// WebSocket and without reconnection.
+func (s *Server) Options() *client.Options {
+	return &client.Options{
+		Transports:          []string{engineio.TransportWebSocket},
+		DisableReconnection: true,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{DialContext: s.listener.DialContext},
+		},
+		Dialer: &websocket.Dialer{NetDialContext: s.listener.DialContext},
+	}
+}
+
+// Connect connects a new client to the namespace, failing the test if it
+// cannot connect within ConnectTimeout.
+func (s *Server) Connect(t testing.TB, namespace string) *Client {
+	t.Helper()
+
+	c, err := s.Dial(namespace, nil)
+	if err != nil {
+		t.Fatalf("siotest: connect to %s: %v", namespace, err)
+	}
+	return c
+}
+
+// Dial connects a new client to the namespace and returns the connection
+// error, such as a *gosocketio.ConnectError if a middleware rejects it.
+//
+// If opts is nil, Options is used. The HTTPClient and Dialer of opts are
+// replaced to connect over the in-memory network.
+func (s *Server) Dial(namespace string, opts *client.Options) (*Client, error) {
+	if opts == nil {
+		opts = s.Options()
+	}
+
+	partitioned := new(atomic.Bool)
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := s.listener.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &partitionConn{Conn: conn, partitioned: partitioned}, nil
+	}
+
+	withNetwork := *opts
+	withNetwork.HTTPClient = &http.Client{Transport: &http.Transport{DialContext: dial}}
+	withNetwork.Dialer = &websocket.Dialer{NetDialContext: dial}
+	opts = &withNetwork
+
+	manager, err := client.NewManager("http://siotest", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.managers = append(s.managers, manager)
+	s.mu.Unlock()
+
+	c := newClient(manager.Socket(namespace), partitioned)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+	defer cancel()
+
+	if err := c.Connect(ctx); err != nil {
+		manager.Close()
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,123 @@
+package siotest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/client"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+func TestEventsAndAcks(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.On("echo", func(args ...interface{}) {
+			socket.Emit("echo", args[0])
+			if ack, ok := args[len(args)-1].(func(...interface{})); ok {
+				ack("done")
+			}
+		})
+	})
+
+	alice := server.Connect(t, "/")
+	response := alice.ExpectAck(t, time.Second, "echo", "hello")
+	if len(response) != 1 || response[0] != "done" {
+		t.Fatalf("got acknowledgment %v, want [done]", response)
+	}
+
+	event := alice.ExpectEvent(t, "echo", time.Second)
+	if len(event.Args) != 1 || event.Args[0] != "hello" {
+		t.Fatalf("got %v, want [hello]", event.Args)
+	}
+	alice.ExpectNoEvent(t, "echo", 50*time.Millisecond)
+}
+
+func TestHandshakeTime(t *testing.T) {
+	server := siotest.NewServer(t, nil)
+	server.Clock.Advance(time.Hour)
+
+	handshakes := make(chan *gosocketio.Handshake, 1)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		handshakes <- socket.Handshake()
+	})
+	server.Connect(t, "/")
+
+	if got := (<-handshakes).Time; !got.Equal(server.Clock.Now()) {
+		t.Fatalf("handshake time %v, want the fake clock time %v", got, server.Clock.Now())
+	}
+}
+
+func TestPingTimeout(t *testing.T) {
+	server := siotest.NewServer(t, &gosocketio.Config{PingInterval: 25000, PingTimeout: 20000})
+
+	reasons := make(chan string, 1)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		socket.OnDisconnect(func(reason string) {
+			reasons <- reason
+		})
+	})
+
+	alice := server.Connect(t, "/")
+	alice.Partition()
+
+	server.Clock.Advance(25 * time.Second) // ping, which alice cannot answer
+	select {
+	case reason := <-reasons:
+		t.Fatalf("disconnected with %q before the ping timeout", reason)
+	default:
+	}
+
+	server.Clock.Advance(20 * time.Second)
+	select {
+	case reason := <-reasons:
+		if reason != "ping timeout" {
+			t.Fatalf("disconnected with %q, want ping timeout", reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not disconnected after the ping timeout")
+	}
+	alice.ExpectDisconnect(t, time.Second)
+}
+
+func TestConnectTimeout(t *testing.T) {
+	server := siotest.NewServer(t, &gosocketio.Config{ConnectTimeout: 45000})
+
+	// The middleware holds the client between the Engine.IO handshake and
+	// joining the namespace
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server.Of("/").Use(func(socket *gosocketio.Socket, next func(error)) {
+		close(entered)
+		go func() {
+			<-release
+			next(nil)
+		}()
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := server.Dial("/", nil)
+		errs <- err
+	}()
+	<-entered
+
+	server.Clock.Advance(44 * time.Second)
+	select {
+	case err := <-errs:
+		t.Fatalf("Dial returned %v before the connect timeout", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	server.Clock.Advance(time.Second)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, client.ErrEngineClosed) {
+			t.Fatalf("Dial returned %v, want ErrEngineClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("client not disconnected after the connect timeout")
+	}
+}
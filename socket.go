@@ -1,12 +1,20 @@
 package gosocketio
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ramory-l/gosocketio/engineio"
 )
 
+// ErrSocketDisconnected is returned for acknowledgments that were still pending
+// when the socket disconnected.
+var ErrSocketDisconnected = errors.New("socket has been disconnected")
+
 // Socket represents a client connection to a Socket.IO namespace.
 //
 // Each socket has a unique ID and can join/leave rooms, emit events,
@@ -15,18 +23,27 @@ import (
 //
 // All methods are goroutine-safe and can be called concurrently.
 type Socket struct {
-	id         string
-	session    *engineio.Session
-	namespace  *Namespace
-	rooms      map[string]bool
-	roomsMu    sync.RWMutex
-	handlers   map[string][]EventHandler
-	handlersMu sync.RWMutex
-	ackID      atomic.Int64
-	ackHandlers sync.Map
-	data       sync.Map
+	id           string
+	session      *engineio.Session
+	client       *client
+	namespace    *Namespace
+	rooms        map[string]bool
+	roomsMu      sync.RWMutex
+	handlers     map[string][]eventHandler
+	middlewares  []EventMiddlewareFunc
+	anyIncoming  []AnyHandler
+	anyOutgoing  []AnyHandler
+	handlersMu   sync.RWMutex
+	dispatcher   dispatcher
+	ackID        atomic.Int64
+	ackHandlers  sync.Map
+	data         sync.Map
+	handshake    *Handshake
+	pid          string
+	recovered    bool
 	onDisconnect []func(string)
 	disconnectMu sync.RWMutex
+	closeOnce    sync.Once
 }
 
 // EventHandler is a function that handles Socket.IO events.
@@ -36,25 +53,74 @@ type Socket struct {
 // that can be called to send the acknowledgment response.
 type EventHandler func(...interface{})
 
+// eventHandler is a registered event handler
+type eventHandler func(args *eventArgs)
+
+// eventArgs holds the arguments of a received event. Arguments received as
+// JSON are kept raw, and only decoded into interface{} values if a middleware
+// or an untyped handler needs them.
+type eventArgs struct {
+	// raw is the JSON encoding of the arguments, or nil if the packet data
+	// was not raw JSON
+	raw []json.RawMessage
+
+	// ack sends the acknowledgment, or is nil if none was requested
+	ack func(...interface{})
+
+	// values returns the decoded arguments, followed by ack if requested.
+	// It returns the same slice on every call.
+	values func() []interface{}
+}
+
+// valueArgs returns arguments that are already decoded
+func valueArgs(values ...interface{}) *eventArgs {
+	return &eventArgs{values: func() []interface{} { return values }}
+}
+
+// AnyHandler is a function that handles any event, along with its name.
+type AnyHandler func(event string, args ...interface{})
+
+// EventMiddlewareFunc is a function that runs for every event received by a
+// socket before it is passed to the event handlers.
+//
+// args are the event arguments; if the client requested an acknowledgment,
+// the last one is the function sending it, like for EventHandler. The
+// middleware may modify args in place.
+//
+// The middleware must call next exactly once. Calling next with a nil error
+// passes the event to the following middleware; calling it with a non-nil
+// error drops the event and passes the error to the handlers registered for
+// the "error" event. The client is told of the rejection: if it requested an
+// acknowledgment, it is sent {"error": message}; otherwise it receives an
+// "error" event with {"event": name, "message": message}.
+//
+// A panicking middleware rejects the event with a *PanicError, which is
+// reported to Server.OnError and the "error" handlers; the client is only
+// sent "internal server error".
+type EventMiddlewareFunc func(event string, args []interface{}, next func(error))
+
 // AckHandler is a function that handles acknowledgment responses.
 //
 // The handler receives the acknowledgment data from the client as
 // variadic arguments.
 type AckHandler func(...interface{})
 
+// ackCallback receives either the acknowledgment data or the error that
+// ended the wait for it.
+type ackCallback func(args []interface{}, err error)
+
 // NewSocket creates a new socket
 func NewSocket(id string, session *engineio.Session, namespace *Namespace) *Socket {
 	socket := &Socket{
-		id:        id,
-		session:   session,
-		namespace: namespace,
-		rooms:     make(map[string]bool),
-		handlers:  make(map[string][]EventHandler),
+		id:         id,
+		session:    session,
+		namespace:  namespace,
+		rooms:      make(map[string]bool),
+		handlers:   make(map[string][]eventHandler),
+		handshake:  newHandshake(session.Request(), nil, namespace.server.clock().Now()),
+		dispatcher: newDispatcher(namespace.server),
 	}
 
-	session.OnMessage(socket.handleMessage)
-	session.OnClose(socket.handleClose)
-
 	return socket
 }
 
@@ -62,10 +128,56 @@ func NewSocket(id string, session *engineio.Session, namespace *Namespace) *Sock
 //
 // The ID is generated by the server and remains constant for the lifetime
 // of the connection. It can be used to target specific clients when broadcasting.
+// A client connected to several namespaces has a distinct socket ID in each.
 func (s *Socket) ID() string {
 	return s.id
 }
 
+// Auth returns the auth payload the client sent when connecting to the namespace.
+//
+// Returns nil if the client did not send one. The payload is typically used by
+// middlewares registered with Namespace.Use to authenticate the connection.
+//
+// Example:
+//
+//	token, _ := socket.Auth()["token"].(string)
+func (s *Socket) Auth() map[string]interface{} {
+	return s.handshake.Auth
+}
+
+// Handshake returns the details of the connection that opened this socket:
+// request headers, query string, remote address, cookies, TLS state, connect
+// time and auth payload.
+//
+// Example:
+//
+//	tenant := socket.Handshake().Headers.Get("X-Tenant")
+func (s *Socket) Handshake() *Handshake {
+	return s.handshake
+}
+
+// Recovered reports whether the socket state was restored by connection
+// state recovery.
+//
+// A recovered socket keeps the ID, rooms and data it had before losing its
+// connection, and has already been sent the broadcasts it missed.
+//
+// Example:
+//
+//	server.OnConnect(func(socket *gosocketio.Socket) {
+//	    if !socket.Recovered() {
+//	        // New session: join rooms, load user data...
+//	    }
+//	})
+func (s *Socket) Recovered() bool {
+	return s.recovered
+}
+
+// Namespace returns the namespace this socket is connected to.
+func (s *Socket) Namespace() *Namespace {
+	return s.namespace
+}
+
 // Emit sends an event to the client.
 //
 // The event name is sent as a string, followed by any number of data arguments.
@@ -76,6 +188,24 @@ func (s *Socket) ID() string {
 //	socket.Emit("message", "Hello, client!")
 //	socket.Emit("user", map[string]interface{}{"name": "John", "age": 30})
 func (s *Socket) Emit(event string, data ...interface{}) error {
+	return s.EmitContext(context.Background(), event, data...)
+}
+
+// EmitContext sends an event to the client like Emit. If the outgoing queue of
+// the client is full and Config.OverflowPolicy is engineio.OverflowBlock, it
+// waits for room until ctx is done and then returns ctx.Err().
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//	defer cancel()
+//
+//	if err := socket.EmitContext(ctx, "tick", n); err != nil {
+//	    log.Printf("Client too slow: %v", err)
+//	}
+func (s *Socket) EmitContext(ctx context.Context, event string, data ...interface{}) error {
+	s.notifyOutgoing(event, data)
+
 	args := make([]interface{}, 0, len(data)+1)
 	args = append(args, event)
 	args = append(args, data...)
@@ -86,7 +216,7 @@ func (s *Socket) Emit(event string, data ...interface{}) error {
 		Data:      args,
 	}
 
-	return s.sendPacket(packet)
+	return s.sendPacketContext(ctx, packet)
 }
 
 // EmitWithAck sends an event to the client and expects an acknowledgment response.
@@ -100,28 +230,163 @@ func (s *Socket) Emit(event string, data ...interface{}) error {
 //	    log.Printf("Client answered: %v", response)
 //	}, "What's your name?")
 func (s *Socket) EmitWithAck(event string, ack AckHandler, data ...interface{}) error {
+	_, err := s.emitWithAck(context.Background(), event, data, func(args []interface{}, err error) {
+		if err == nil {
+			ack(args...)
+		}
+	})
+	return err
+}
+
+// EmitWithAckContext sends an event to the client and waits for its acknowledgment.
+//
+// It returns the acknowledgment data, or an error if the context is done before
+// the client responds (ctx.Err()) or the socket disconnects (ErrSocketDisconnected).
+// In both cases the pending acknowledgment is discarded.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//
+//	response, err := socket.EmitWithAckContext(ctx, "question", "What's your name?")
+//	if err != nil {
+//	    log.Printf("No answer: %v", err)
+//	}
+func (s *Socket) EmitWithAckContext(ctx context.Context, event string, data ...interface{}) ([]interface{}, error) {
+	type result struct {
+		args []interface{}
+		err  error
+	}
+
+	done := make(chan result, 1)
+	id, err := s.emitWithAck(ctx, event, data, func(args []interface{}, err error) {
+		done <- result{args, err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-done:
+		return r.args, r.err
+	case <-ctx.Done():
+		s.ackHandlers.Delete(id)
+		return nil, ctx.Err()
+	}
+}
+
+// Timeout returns an emitter whose acknowledgments fail with
+// context.DeadlineExceeded if the client does not respond within d.
+//
+// Example:
+//
+//	socket.Timeout(5*time.Second).EmitWithAck("question", func(err error, response ...interface{}) {
+//	    if err != nil {
+//	        log.Printf("Client did not answer in time")
+//	        return
+//	    }
+//	    log.Printf("Client answered: %v", response)
+//	}, "What's your name?")
+func (s *Socket) Timeout(d time.Duration) *TimeoutEmitter {
+	return &TimeoutEmitter{socket: s, timeout: d}
+}
+
+// TimeoutEmitter emits events whose acknowledgments expire after a fixed timeout.
+//
+// It is created with Socket.Timeout.
+type TimeoutEmitter struct {
+	socket  *Socket
+	timeout time.Duration
+}
+
+// EmitWithAck sends an event to the client and calls ack with the response, or
+// with a non-nil error if the timeout expires or the socket disconnects first.
+func (e *TimeoutEmitter) EmitWithAck(event string, ack func(err error, response ...interface{}), data ...interface{}) error {
+	var (
+		timer   engineio.Timer
+		timerMu sync.Mutex
+	)
+
+	// Hold the lock until the timer exists so an early response can stop it.
+	// The timer is still nil if the acknowledgment fails because sending did.
+	timerMu.Lock()
+	defer timerMu.Unlock()
+
+	id, err := e.socket.emitWithAck(context.Background(), event, data, func(args []interface{}, err error) {
+		timerMu.Lock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timerMu.Unlock()
+
+		ack(err, args...)
+	})
+	if err != nil {
+		return err
+	}
+
+	timer = e.socket.namespace.server.clock().AfterFunc(e.timeout, func() {
+		if val, ok := e.socket.ackHandlers.LoadAndDelete(id); ok {
+			e.socket.callAck(val.(ackCallback), nil, context.DeadlineExceeded)
+		}
+	})
+
+	return nil
+}
+
+// EmitWithAckContext sends an event to the client and waits for its acknowledgment,
+// for at most the emitter's timeout.
+//
+// See Socket.EmitWithAckContext.
+func (e *TimeoutEmitter) EmitWithAckContext(ctx context.Context, event string, data ...interface{}) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	return e.socket.EmitWithAckContext(ctx, event, data...)
+}
+
+// emitWithAck sends an event with a new ack ID and registers the callback for
+// its acknowledgment.
+func (s *Socket) emitWithAck(ctx context.Context, event string, data []interface{}, callback ackCallback) (int, error) {
+	s.notifyOutgoing(event, data)
+
 	args := make([]interface{}, 0, len(data)+1)
 	args = append(args, event)
 	args = append(args, data...)
 
-	id := int(s.ackID.Add(1))
-
 	packet := &Packet{
 		Type:      PacketTypeEvent,
 		Namespace: s.namespace.name,
 		Data:      args,
-		ID:        &id,
 	}
 
-	s.ackHandlers.Store(id, ack)
+	return s.sendWithAck(ctx, packet, callback)
+}
+
+// sendWithAck sends a copy of the packet with a new ack ID and registers the
+// callback for its acknowledgment.
+func (s *Socket) sendWithAck(ctx context.Context, packet *Packet, callback ackCallback) (int, error) {
+	id := int(s.ackID.Add(1))
+
+	withID := *packet
+	withID.ID = &id
+
+	s.ackHandlers.Store(id, callback)
+
+	if err := s.sendPacketContext(ctx, &withID); err != nil {
+		s.ackHandlers.Delete(id)
+		return 0, err
+	}
 
-	return s.sendPacket(packet)
+	return id, nil
 }
 
 // On registers an event handler for the specified event.
 //
 // Multiple handlers can be registered for the same event. They will be called
-// in the order they were registered. Each handler is called in its own goroutine.
+// in the order they were registered. By default each handler is called in its
+// own goroutine; see Config.DispatchMode for ordered processing.
 //
 // If the client requests an acknowledgment when emitting the event, the last
 // argument passed to the handler will be a function that can be called to send
@@ -143,6 +408,12 @@ func (s *Socket) EmitWithAck(event string, ack AckHandler, data ...interface{})
 //	    }
 //	})
 func (s *Socket) On(event string, handler EventHandler) {
+	s.on(event, func(args *eventArgs) {
+		handler(args.values()...)
+	})
+}
+
+func (s *Socket) on(event string, handler eventHandler) {
 	s.handlersMu.Lock()
 	s.handlers[event] = append(s.handlers[event], handler)
 	s.handlersMu.Unlock()
@@ -159,6 +430,89 @@ func (s *Socket) Off(event string) {
 	s.handlersMu.Unlock()
 }
 
+// OnAny registers a handler called for every event received by the socket,
+// whether or not handlers are registered for it.
+//
+// It is run like handlers registered with On, before them, after the socket
+// middlewares accepted the event. If the client requested an
+// acknowledgment, the last argument is the function sending it.
+//
+// Example:
+//
+//	socket.OnAny(func(event string, args ...interface{}) {
+//	    log.Printf("Received %s: %v", event, args)
+//	})
+func (s *Socket) OnAny(handler AnyHandler) {
+	s.handlersMu.Lock()
+	s.anyIncoming = append(s.anyIncoming, handler)
+	s.handlersMu.Unlock()
+}
+
+// PrependAny registers a handler like OnAny, but ahead of the handlers already
+// registered.
+func (s *Socket) PrependAny(handler AnyHandler) {
+	s.handlersMu.Lock()
+	s.anyIncoming = append([]AnyHandler{handler}, s.anyIncoming...)
+	s.handlersMu.Unlock()
+}
+
+// OffAny removes all handlers registered with OnAny and PrependAny.
+func (s *Socket) OffAny() {
+	s.handlersMu.Lock()
+	s.anyIncoming = nil
+	s.handlersMu.Unlock()
+}
+
+// OnAnyOutgoing registers a handler called for every event emitted to the
+// client with Emit or EmitWithAck, before it is sent.
+//
+// It is called synchronously by the emitting goroutine and must not modify
+// the arguments. Broadcasts are not reported.
+//
+// Example:
+//
+//	socket.OnAnyOutgoing(func(event string, args ...interface{}) {
+//	    log.Printf("Sending %s: %v", event, args)
+//	})
+func (s *Socket) OnAnyOutgoing(handler AnyHandler) {
+	s.handlersMu.Lock()
+	s.anyOutgoing = append(s.anyOutgoing, handler)
+	s.handlersMu.Unlock()
+}
+
+// OffAnyOutgoing removes all handlers registered with OnAnyOutgoing.
+func (s *Socket) OffAnyOutgoing() {
+	s.handlersMu.Lock()
+	s.anyOutgoing = nil
+	s.handlersMu.Unlock()
+}
+
+// Use registers a middleware that runs for every event received by the socket.
+//
+// Middlewares run in the order they were registered, in the goroutine reading
+// from the connection, so slow checks should call next from another goroutine.
+// A middleware rejecting an event keeps it from reaching the handlers and
+// answers the client with the error, as described for EventMiddlewareFunc.
+//
+// Example:
+//
+//	socket.Use(func(event string, args []interface{}, next func(error)) {
+//	    if !limiter.Allow() {
+//	        next(errors.New("rate limited"))
+//	        return
+//	    }
+//	    next(nil)
+//	})
+//
+//	socket.On("error", func(args ...interface{}) {
+//	    log.Printf("Event rejected: %v", args[0])
+//	})
+func (s *Socket) Use(fn EventMiddlewareFunc) {
+	s.handlersMu.Lock()
+	s.middlewares = append(s.middlewares, fn)
+	s.handlersMu.Unlock()
+}
+
 // Join adds the socket to the specified room.
 //
 // Rooms are arbitrary channels that sockets can join and leave. They provide
@@ -215,6 +569,19 @@ func (s *Socket) Rooms() []string {
 	return rooms
 }
 
+// inAnyRoom reports whether the socket has joined any of the rooms
+func (s *Socket) inAnyRoom(rooms []string) bool {
+	s.roomsMu.RLock()
+	defer s.roomsMu.RUnlock()
+
+	for _, room := range rooms {
+		if s.rooms[room] {
+			return true
+		}
+	}
+	return false
+}
+
 // Set stores arbitrary data on the socket.
 //
 // This is useful for storing user-specific data such as user ID, session info, etc.
@@ -258,61 +625,75 @@ func (s *Socket) OnDisconnect(handler func(string)) {
 	s.disconnectMu.Unlock()
 }
 
-// Disconnect forcefully disconnects the socket from the server.
+// Disconnect disconnects the socket from its namespace.
 //
-// This will trigger OnDisconnect handlers with reason "server disconnect".
+// The client is sent a DISCONNECT packet for this namespace. Sockets the same
+// client holds in other namespaces are not affected. This will trigger
+// OnDisconnect handlers with reason "server namespace disconnect".
 //
 // Example:
 //
 //	socket.Disconnect()
 func (s *Socket) Disconnect() {
+	s.sendPacket(&Packet{
+		Type:      PacketTypeDisconnect,
+		Namespace: s.namespace.name,
+	})
+	s.handleClose("server namespace disconnect")
+}
+
+// Close closes the underlying connection, disconnecting the client from
+// every namespace it has joined.
+//
+// This will trigger OnDisconnect handlers with reason "server disconnect".
+//
+// Example:
+//
+//	socket.Close()
+func (s *Socket) Close() {
 	s.session.Close("server disconnect")
 }
 
 func (s *Socket) sendPacket(packet *Packet) error {
-	encoded, err := packet.Encode()
+	return s.sendPacketContext(context.Background(), packet)
+}
+
+// sendPacketContext sends the packet and its binary attachments, waiting
+// until ctx is done if the outgoing queue is full and blocking
+func (s *Socket) sendPacketContext(ctx context.Context, packet *Packet) error {
+	packets, err := encodePacket(s.namespace.server.parser(), packet)
 	if err != nil {
 		return err
 	}
 
-	return s.session.Send(&engineio.Packet{
-		Type: engineio.PacketTypeMessage,
-		Data: []byte(encoded),
-	})
+	return s.session.SendContext(ctx, packets...)
 }
 
-func (s *Socket) handleMessage(data []byte) {
-	packet, err := DecodePacket(string(data))
-	if err != nil {
-		return
-	}
-
+func (s *Socket) handlePacket(packet *Packet) {
 	switch packet.Type {
-	case PacketTypeEvent:
+	case PacketTypeEvent, PacketTypeBinaryEvent:
 		s.handleEvent(packet)
-	case PacketTypeAck:
+	case PacketTypeAck, PacketTypeBinaryAck:
 		s.handleAck(packet)
 	case PacketTypeDisconnect:
-		s.Disconnect()
+		s.handleClose("client namespace disconnect")
 	}
 }
 
 func (s *Socket) handleEvent(packet *Packet) {
-	dataArray, ok := packet.Data.([]interface{})
-	if !ok || len(dataArray) == 0 {
-		return
-	}
-
-	event, ok := dataArray[0].(string)
+	event, args, ok := parseEvent(packet)
 	if !ok {
 		return
 	}
 
-	args := dataArray[1:]
-
 	// Handle acknowledgment
 	if packet.ID != nil {
-		ackFunc := func(ackData ...interface{}) {
+		var sent atomic.Bool
+		args.ack = func(ackData ...interface{}) {
+			// Only the first acknowledgment is sent
+			if !sent.CompareAndSwap(false, true) {
+				return
+			}
 			ackPacket := &Packet{
 				Type:      PacketTypeAck,
 				Namespace: s.namespace.name,
@@ -321,18 +702,162 @@ func (s *Socket) handleEvent(packet *Packet) {
 			}
 			s.sendPacket(ackPacket)
 		}
-		args = append(args, ackFunc)
 	}
 
+	s.runMiddlewares(event, args, func(err error) {
+		if err != nil {
+			s.refuseEvent(event, err, args.ack)
+			return
+		}
+		s.dispatchAny(event, args)
+		s.dispatch(event, args)
+	})
+}
+
+// parseEvent returns the name and arguments of an EVENT packet. The ack of
+// the arguments is left to the caller.
+func parseEvent(packet *Packet) (string, *eventArgs, bool) {
+	args := &eventArgs{}
+	var event string
+	var values []interface{}
+
+	if data, ok := packet.Data.(json.RawMessage); ok {
+		if json.Unmarshal(data, &args.raw) != nil || len(args.raw) == 0 {
+			return "", nil, false
+		}
+		if json.Unmarshal(args.raw[0], &event) != nil {
+			return "", nil, false
+		}
+		args.raw = args.raw[1:]
+	} else {
+		dataArray, _ := packet.Data.([]interface{})
+		if len(dataArray) == 0 {
+			return "", nil, false
+		}
+		var ok bool
+		if event, ok = dataArray[0].(string); !ok {
+			return "", nil, false
+		}
+		values = dataArray[1:]
+	}
+
+	args.values = sync.OnceValue(func() []interface{} {
+		if args.raw != nil {
+			values = make([]interface{}, len(args.raw), len(args.raw)+1)
+			for i, arg := range args.raw {
+				// The packet data is valid JSON
+				json.Unmarshal(arg, &values[i])
+			}
+		}
+		if args.ack != nil {
+			values = append(values[:len(values):len(values)], args.ack)
+		}
+		return values
+	})
+	return event, args, true
+}
+
+// refuseEvent reports an event rejected by a middleware or a typed handler to
+// the "error" handlers and to the client, with an error acknowledgment if it
+// requested one or else an "error" event. The client is never sent the value
+// of a panic.
+func (s *Socket) refuseEvent(event string, err error, ack func(...interface{})) {
+	// The value of a panic is only for the server
+	message := err.Error()
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		message = errInternal.Error()
+	}
+
+	if ack != nil {
+		ack(map[string]interface{}{"error": message})
+	} else {
+		s.Emit("error", map[string]interface{}{"event": event, "message": message})
+	}
+	s.dispatch("error", valueArgs(err))
+}
+
+// dispatch calls the handlers registered for the event
+func (s *Socket) dispatch(event string, args *eventArgs) {
 	s.handlersMu.RLock()
 	handlers := s.handlers[event]
 	s.handlersMu.RUnlock()
 
 	for _, handler := range handlers {
-		go handler(args...)
+		handler := handler
+		s.dispatcher.dispatch(event, func() {
+			s.runHandler(event, args.ack, func() { handler(args) })
+		})
 	}
 }
 
+// dispatchAny calls the handlers registered with OnAny
+func (s *Socket) dispatchAny(event string, args *eventArgs) {
+	s.handlersMu.RLock()
+	handlers := s.anyIncoming
+	s.handlersMu.RUnlock()
+
+	for _, handler := range handlers {
+		handler := handler
+		s.dispatcher.dispatch(event, func() {
+			s.runHandler(event, args.ack, func() { handler(event, args.values()...) })
+		})
+	}
+}
+
+// notifyOutgoing calls the handlers registered with OnAnyOutgoing
+func (s *Socket) notifyOutgoing(event string, args []interface{}) {
+	s.handlersMu.RLock()
+	handlers := s.anyOutgoing
+	s.handlersMu.RUnlock()
+
+	for _, handler := range handlers {
+		s.safeCall(event, func() { handler(event, args...) })
+	}
+}
+
+func (s *Socket) runMiddlewares(event string, args *eventArgs, done func(error)) {
+	s.handlersMu.RLock()
+	middlewares := s.middlewares
+	s.handlersMu.RUnlock()
+
+	if len(middlewares) == 0 {
+		done(nil)
+		return
+	}
+	values := args.values()
+
+	// A panicking middleware rejects the event, unless it already called next
+	var once sync.Once
+	finish := func(err error) {
+		once.Do(func() { done(err) })
+	}
+
+	var run func(i int)
+	run = func(i int) {
+		if i == len(middlewares) {
+			finish(nil)
+			return
+		}
+
+		defer func() {
+			if value := recover(); value != nil {
+				finish(s.namespace.server.reportPanic(value, s, event))
+			}
+		}()
+
+		middlewares[i](event, values, func(err error) {
+			if err != nil {
+				finish(err)
+				return
+			}
+			run(i + 1)
+		})
+	}
+
+	run(0)
+}
+
 func (s *Socket) handleAck(packet *Packet) {
 	if packet.ID == nil {
 		return
@@ -343,17 +868,37 @@ func (s *Socket) handleAck(packet *Packet) {
 		return
 	}
 
-	handler := val.(AckHandler)
+	callback := val.(ackCallback)
 
-	var args []interface{}
-	if dataArray, ok := packet.Data.([]interface{}); ok {
-		args = dataArray
-	}
+	go s.callAck(callback, packet.decodedArgs(), nil)
+}
 
-	go handler(args...)
+// callAck calls an acknowledgment callback, recovering from and reporting
+// any panic
+func (s *Socket) callAck(callback ackCallback, args []interface{}, err error) {
+	defer s.namespace.server.recoverPanic(s, "")
+	callback(args, err)
 }
 
 func (s *Socket) handleClose(reason string) {
+	s.closeOnce.Do(func() {
+		s.close(reason)
+	})
+}
+
+func (s *Socket) close(reason string) {
+	// Keep the state for connection state recovery
+	if s.pid != "" && recoverableReasons[reason] {
+		if adapter, ok := s.namespace.adapter.(SessionAwareAdapter); ok {
+			adapter.PersistSession(&SessionState{
+				SID:   s.id,
+				PID:   s.pid,
+				Rooms: s.Rooms(),
+				Data:  s.snapshotData(),
+			})
+		}
+	}
+
 	// Leave all rooms
 	s.roomsMu.RLock()
 	rooms := make([]string, 0, len(s.rooms))
@@ -366,15 +911,38 @@ func (s *Socket) handleClose(reason string) {
 		s.Leave(room)
 	}
 
+	// Fail pending acknowledgments
+	s.ackHandlers.Range(func(key, val interface{}) bool {
+		if _, ok := s.ackHandlers.LoadAndDelete(key); ok {
+			go s.callAck(val.(ackCallback), nil, ErrSocketDisconnected)
+		}
+		return true
+	})
+
 	// Call disconnect handlers
 	s.disconnectMu.RLock()
 	handlers := s.onDisconnect
 	s.disconnectMu.RUnlock()
 
 	for _, handler := range handlers {
-		go handler(reason)
+		handler := handler
+		go s.safeCall("disconnect", func() { handler(reason) })
 	}
 
-	// Remove from namespace
+	// Remove from namespace and client
 	s.namespace.removeSocket(s.id)
+	if s.client != nil {
+		s.client.removeSocket(s)
+	}
+}
+
+func (s *Socket) snapshotData() map[string]interface{} {
+	data := make(map[string]interface{})
+	s.data.Range(func(key, value interface{}) bool {
+		if k, ok := key.(string); ok {
+			data[k] = value
+		}
+		return true
+	})
+	return data
 }
@@ -0,0 +1,128 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrMissingPayload is returned by typed handlers for events received
+// without the argument they decode.
+var ErrMissingPayload = errors.New("missing payload")
+
+// Validator is implemented by payloads that check their own content. Typed
+// handlers call Validate after decoding a payload and reject the event if it
+// returns an error.
+type Validator interface {
+	Validate() error
+}
+
+// On registers a typed handler for the event on the socket.
+//
+// The first argument of the event is decoded into T directly from the JSON
+// received, instead of going through interface{} values. If the argument is
+// missing (ErrMissingPayload), if decoding or validation fails, or if the
+// handler returns an error, the event is rejected like by a socket middleware:
+// the error is passed to the handlers registered for the "error" event, and
+// the client is sent {"error": message} if it requested an acknowledgment, or
+// else an "error" event with {"event": name, "message": message}.
+//
+// Typed handlers decode the arguments as received: changes made to them by
+// socket middlewares are not visible.
+//
+// Example:
+//
+//	type ChatMessage struct {
+//	    Room string `json:"room"`
+//	    Text string `json:"text"`
+//	}
+//
+//	gosocketio.On(socket, "chat", func(s *gosocketio.Socket, msg ChatMessage) error {
+//	    return s.Namespace().To(msg.Room).Emit("chat", msg)
+//	})
+func On[T any](socket *Socket, event string, handler func(s *Socket, payload T) error) {
+	socket.on(event, func(args *eventArgs) {
+		payload, err := decodeArg[T](event, args, 0)
+		if err == nil {
+			err = handler(socket, payload)
+		}
+		if err != nil {
+			socket.refuseEvent(event, err, args.ack)
+		}
+	})
+}
+
+// OnWithAck registers a typed handler for an event expecting an acknowledgment.
+//
+// It behaves like On, and the handler answers with ack. If the client did not
+// request an acknowledgment, ack does nothing. Only the first answer is sent.
+//
+// Example:
+//
+//	gosocketio.OnWithAck(socket, "join", func(s *gosocketio.Socket, req JoinRequest, ack func(JoinResponse)) error {
+//	    if req.Room == "" {
+//	        return errors.New("room is required")
+//	    }
+//	    s.Join(req.Room)
+//	    ack(JoinResponse{Room: req.Room})
+//	    return nil
+//	})
+func OnWithAck[T, R any](socket *Socket, event string, handler func(s *Socket, payload T, ack func(R)) error) {
+	socket.on(event, func(args *eventArgs) {
+		respond := func(response R) {
+			if args.ack != nil {
+				args.ack(response)
+			}
+		}
+
+		payload, err := decodeArg[T](event, args, 0)
+		if err == nil {
+			err = handler(socket, payload, respond)
+		}
+		if err != nil {
+			socket.refuseEvent(event, err, args.ack)
+		}
+	})
+}
+
+// decodeArg decodes the i-th event argument into T, from its raw JSON if
+// available, and validates it
+func decodeArg[T any](event string, args *eventArgs, i int) (T, error) {
+	var payload T
+	var err error
+
+	if args.raw != nil {
+		if i >= len(args.raw) {
+			return payload, fmt.Errorf("%w for %q", ErrMissingPayload, event)
+		}
+		err = json.Unmarshal(args.raw[i], &payload)
+	} else {
+		values := args.values()
+		if args.ack != nil {
+			values = values[:len(values)-1]
+		}
+		if i >= len(values) {
+			return payload, fmt.Errorf("%w for %q", ErrMissingPayload, event)
+		}
+
+		// Binary packets have no raw JSON: convert the decoded value
+		if value, ok := values[i].(T); ok {
+			payload = value
+		} else {
+			var data []byte
+			if data, err = json.Marshal(values[i]); err == nil {
+				err = json.Unmarshal(data, &payload)
+			}
+		}
+	}
+	if err != nil {
+		return payload, fmt.Errorf("invalid payload for %q: %w", event, err)
+	}
+
+	if validator, ok := any(payload).(Validator); ok {
+		err = validator.Validate()
+	} else if validator, ok := any(&payload).(Validator); ok {
+		err = validator.Validate()
+	}
+	return payload, err
+}
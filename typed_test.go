@@ -0,0 +1,106 @@
+package gosocketio_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramory-l/gosocketio"
+	"github.com/ramory-l/gosocketio/siotest"
+)
+
+type joinRequest struct {
+	Room string `json:"room"`
+}
+
+func (r joinRequest) Validate() error {
+	if r.Room == "" {
+		return errors.New("room is required")
+	}
+	return nil
+}
+
+func newTypedServer(t *testing.T, errs chan<- error) *siotest.Server {
+	server := siotest.NewServer(t, nil)
+	server.OnConnect(func(socket *gosocketio.Socket) {
+		gosocketio.OnWithAck(socket, "join", func(s *gosocketio.Socket, req joinRequest, ack func(string)) error {
+			ack("joined " + req.Room)
+			return nil
+		})
+		socket.On("error", func(args ...interface{}) {
+			err, _ := args[0].(error)
+			errs <- err
+		})
+	})
+	return server
+}
+
+func TestTypedHandler(t *testing.T) {
+	server := newTypedServer(t, make(chan error, 1))
+	alice := server.Connect(t, "/")
+
+	response := alice.ExpectAck(t, time.Second, "join", map[string]interface{}{"room": "lobby"})
+	if len(response) != 1 || response[0] != "joined lobby" {
+		t.Fatalf("got %v, want [joined lobby]", response)
+	}
+}
+
+func TestTypedHandlerRejectsPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []interface{}
+		wantErr error
+	}{
+		{"missing", nil, gosocketio.ErrMissingPayload},
+		{"invalid", []interface{}{"lobby"}, nil},
+		{"not valid", []interface{}{map[string]interface{}{}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := make(chan error, 1)
+			server := newTypedServer(t, errs)
+			alice := server.Connect(t, "/")
+
+			response := alice.ExpectAck(t, time.Second, "join", tt.args...)
+			result, _ := response[0].(map[string]interface{})
+			if _, ok := result["error"].(string); !ok {
+				t.Fatalf("got %v, want an error acknowledgment", response)
+			}
+
+			select {
+			case err := <-errs:
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("error handlers not called")
+			}
+		})
+	}
+}
+
+func TestTypedHandlerRejectsWithoutAck(t *testing.T) {
+	errs := make(chan error, 1)
+	server := newTypedServer(t, errs)
+	alice := server.Connect(t, "/")
+
+	// Without an acknowledgment, the client is sent an "error" event
+	if err := alice.Emit("join", "lobby"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	event := alice.ExpectEvent(t, "error", time.Second)
+	result, _ := event.Args[0].(map[string]interface{})
+	if _, ok := result["message"].(string); !ok || result["event"] != "join" {
+		t.Fatalf("got error event %v, want the event and its error", event.Args)
+	}
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("error handlers called without an error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error handlers not called")
+	}
+}